@@ -163,11 +163,13 @@ func main() {
 						}
 					}()
 
-					mux := astits.NewMuxer(context.Background(), outWriter)
+					mux := astits.NewMuxer(context.Background(), outWriter,
+						astits.MuxerOptAutodetectPCRPID(),
+						astits.MuxerOptPCRInterval(40*time.Millisecond),
+					)
 					if err = mux.AddElementaryStream(*es); err != nil {
 						log.Fatalf("%v", err)
 					}
-					mux.SetPCRPID(es.ElementaryPID)
 					muxers[es.ElementaryPID] = mux
 
 					if !pmtsPrinted {
@@ -197,28 +199,13 @@ func main() {
 			continue
 		}
 
+		// PCRs are inserted by the muxer
 		af := d.FirstPacket.AdaptationField
 
 		if af != nil && af.HasPCR {
 			af.HasPCR = false
 		}
 
-		var pcr *astits.ClockReference
-		switch d.PES.Header.OptionalHeader.PTSDTSIndicator {
-		case astits.PTSDTSIndicatorOnlyPTS:
-			pcr = d.PES.Header.OptionalHeader.PTS
-		case astits.PTSDTSIndicatorBothPresent:
-			pcr = d.PES.Header.OptionalHeader.DTS
-		}
-
-		if pcr != nil {
-			if af == nil {
-				af = &astits.PacketAdaptationField{}
-			}
-			af.HasPCR = true
-			af.PCR = pcr
-		}
-
 		var written int
 		if written, err = mux.WriteData(&astits.MuxerData{
 			PID:             pid,
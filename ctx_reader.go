@@ -0,0 +1,206 @@
+package astits
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Size of the buffer blocking reads are made in, so that following reads don't block
+const ctxReaderBufferSize = 128 * MpegTsPacketSize
+
+// readDeadliner represents a reader whose blocking reads can be interrupted, such as a net.Conn
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// ctxReader represents a reader whose reads return as soon as its context is done, even if the
+// underlying read is still blocking
+// Reads that can't block, such as reads of in-memory readers or of bytes already buffered, are made directly.
+// Otherwise the operation is made by a goroutine, in a buffer of its own, that exits once the operation has
+// returned, and whose result is waited for along the context. If the underlying reader supports read deadlines, the
+// blocking read is interrupted once the context is done and its read deadline is cleared once the read has returned.
+// Otherwise it keeps running in the background until the underlying reader returns.
+// Since operations are only made after the previous one has returned or the context is done, they're never
+// concurrent.
+type ctxReader struct {
+	b   []byte // Bytes read in advance
+	buf []byte
+	ctx context.Context
+	err error       // Error returned along the bytes read in advance
+	m   *sync.Mutex // Locks deadlineSet and pending
+	r   io.Reader
+	res chan ctxReadResult
+
+	deadlineSet bool // Whether the read deadline of the underlying reader has been set to interrupt a pending operation
+	pending     bool // Whether an operation is being executed
+}
+
+type ctxReadResult struct {
+	b   []byte
+	err error
+}
+
+// ctxPeekReader represents a ctxReader whose underlying reader can be peeked
+type ctxPeekReader struct {
+	*ctxReader
+	br *bufio.Reader
+}
+
+// ctxReadSeeker represents a ctxReader whose underlying reader can be seeked
+type ctxReadSeeker struct {
+	*ctxReader
+	s io.Seeker
+}
+
+// newCtxReader wraps the reader only if the context can be done
+// The returned reader can be peeked if the reader is a *bufio.Reader and seeked if the reader is an io.Seeker.
+func newCtxReader(ctx context.Context, r io.Reader) io.Reader {
+	if ctx.Done() == nil {
+		return r
+	}
+	cr := &ctxReader{
+		ctx: ctx,
+		m:   &sync.Mutex{},
+		r:   r,
+		res: make(chan ctxReadResult, 1),
+	}
+	switch v := r.(type) {
+	case *bufio.Reader:
+		return &ctxPeekReader{ctxReader: cr, br: v}
+	case io.Seeker:
+		return &ctxReadSeeker{ctxReader: cr, s: v}
+	}
+	return cr
+}
+
+// canBlock checks whether reading n bytes from the underlying reader can block
+func (r *ctxReader) canBlock(n int) bool {
+	switch v := r.r.(type) {
+	case *bufio.Reader:
+		return v.Buffered() < n
+	case interface{ Len() int }:
+		// In-memory readers such as *bytes.Reader, *bytes.Buffer or *strings.Reader
+		return false
+	}
+	return true
+}
+
+// do executes the operation in a goroutine and waits for it or the ctx
+func (r *ctxReader) do(op func() ctxReadResult) (res ctxReadResult) {
+	// Execute operation
+	r.m.Lock()
+	r.pending = true
+	r.m.Unlock()
+	go func() {
+		res := op()
+		r.m.Lock()
+		r.pending = false
+		if r.deadlineSet {
+			// Clear the read deadline once the interrupted operation has returned
+			r.r.(readDeadliner).SetReadDeadline(time.Time{})
+			r.deadlineSet = false
+		}
+		r.m.Unlock()
+		r.res <- res
+	}()
+
+	// Wait for the operation or the ctx
+	select {
+	case res = <-r.res:
+	case <-r.ctx.Done():
+		// Interrupt the blocking read if possible
+		if d, ok := r.r.(readDeadliner); ok {
+			r.m.Lock()
+			if r.pending {
+				d.SetReadDeadline(time.Now())
+				r.deadlineSet = true
+			}
+			r.m.Unlock()
+		}
+
+		// Since the ctx can't be undone, every following operation returns the ctx error as well, therefore
+		// the pending operation result can safely be dropped
+		res = ctxReadResult{err: r.ctx.Err()}
+	}
+	return
+}
+
+// Read implements the io.Reader interface
+func (r *ctxReader) Read(p []byte) (n int, err error) {
+	// Check ctx error
+	if err = r.ctx.Err(); err != nil {
+		return
+	}
+
+	// Bytes have been read in advance
+	if len(r.b) > 0 {
+		n = copy(p, r.b)
+		r.b = r.b[n:]
+		return
+	} else if r.err != nil {
+		err, r.err = r.err, nil
+		return
+	}
+
+	// Read can't block
+	if !r.canBlock(len(p)) {
+		return r.r.Read(p)
+	}
+
+	// We don't read directly in p since it may still be written to after we've returned. Peekable readers are
+	// not read in advance since their buffer would be out of sync.
+	l := len(p)
+	if _, ok := r.r.(*bufio.Reader); !ok && l < ctxReaderBufferSize {
+		l = ctxReaderBufferSize
+	}
+	res := r.do(func() ctxReadResult {
+		if len(r.buf) < l {
+			r.buf = make([]byte, l)
+		}
+		n, err := r.r.Read(r.buf[:l])
+		return ctxReadResult{b: r.buf[:n], err: err}
+	})
+	n = copy(p, res.b)
+	if r.b = res.b[n:]; len(r.b) > 0 {
+		r.err = res.err
+		return n, nil
+	}
+	return n, res.err
+}
+
+// Peek peeks the underlying *bufio.Reader
+func (r *ctxPeekReader) Peek(n int) ([]byte, error) {
+	// Check ctx error
+	if err := r.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Peek can't block
+	if !r.canBlock(n) {
+		return r.br.Peek(n)
+	}
+
+	res := r.do(func() ctxReadResult {
+		b, err := r.br.Peek(n)
+		return ctxReadResult{b: b, err: err}
+	})
+	return res.b, res.err
+}
+
+// Seek implements the io.Seeker interface
+func (r *ctxReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	// Check ctx error
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// Bytes read in advance are discarded
+	if whence == io.SeekCurrent {
+		offset -= int64(len(r.b))
+	}
+	r.b, r.err = nil, nil
+	return r.s.Seek(offset, whence)
+}
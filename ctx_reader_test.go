@@ -0,0 +1,132 @@
+package astits
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCtxReader(t *testing.T) {
+	// Context can't be done
+	r := bytes.NewReader([]byte("test"))
+	assert.Equal(t, r, newCtxReader(context.Background(), r))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Peek and seek are passed through
+	br := newCtxReader(ctx, bufio.NewReader(bytes.NewReader([]byte("test"))))
+	b, err := br.(*ctxPeekReader).Peek(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("te"), b)
+	_, ok := br.(io.Seeker)
+	assert.False(t, ok)
+	sr := newCtxReader(ctx, r)
+	b = make([]byte, 4)
+	n, err := sr.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	o, err := sr.(io.Seeker).Seek(1, io.SeekStart)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), o)
+	n, err = sr.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("est"), b[:n])
+
+	// Bytes read in advance are taken into account when seeking
+	f, err := ioutil.TempFile("", "astits")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.WriteString("test")
+	assert.NoError(t, err)
+	_, err = f.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	fr := newCtxReader(ctx, f)
+	n, err = fr.Read(b[:2])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("te"), b[:n])
+	o, err = fr.(io.Seeker).Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), o)
+	n, err = fr.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("st"), b[:n])
+}
+
+func TestCtxReaderReadDeadline(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	// Blocking read is interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	r := newCtxReader(ctx, c1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err := r.Read(make([]byte, 4))
+	assert.Equal(t, context.Canceled, err)
+
+	// Read deadline is cleared once the interrupted read has returned
+	cr := r.(*ctxReader)
+	for {
+		cr.m.Lock()
+		pending := cr.pending
+		cr.m.Unlock()
+		if !pending {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	go c2.Write([]byte("test"))
+	n, err := c1.Read(make([]byte, 4))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+}
+
+func TestCtxReaderGoroutines(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := runtime.NumGoroutine()
+
+	// Demuxers are discarded while the ctx is still running
+	for i := 0; i < 10; i++ {
+		c1, c2 := net.Pipe()
+		go func() {
+			for j := 0; j < 10; j++ {
+				c2.Write(patPacketBytes(uint8(j), 0x60739f61))
+			}
+			c2.Close()
+		}()
+		dmx := NewDemuxer(ctx, c1, DemuxerOptPacketSize(MpegTsPacketSize))
+		_, err := dmx.NextPacket()
+		assert.NoError(t, err)
+		for err == nil {
+			_, err = dmx.NextPacket()
+		}
+		assert.Equal(t, ErrNoMorePackets, err)
+		c1.Close()
+
+		// In-memory readers are read directly
+		dmx = NewDemuxer(ctx, bytes.NewReader(patPacketBytes(0, 0x60739f61)), DemuxerOptPacketSize(MpegTsPacketSize))
+		_, err = dmx.NextPacket()
+		assert.NoError(t, err)
+	}
+
+	// No goroutine is left running
+	for i := 0; i < 100 && runtime.NumGoroutine() > n; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= n)
+}
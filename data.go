@@ -11,31 +11,59 @@ const (
 	PIDPAT  uint16 = 0x0    // Program Association Table (PAT) contains a directory listing of all Program Map Tables.
 	PIDCAT  uint16 = 0x1    // Conditional Access Table (CAT) contains a directory listing of all ITU-T Rec. H.222 entitlement management message streams used by Program Map Tables.
 	PIDTSDT uint16 = 0x2    // Transport Stream Description Table (TSDT) contains descriptors related to the overall transport stream
+	PIDNIT  uint16 = 0x10   // Network Information Table (NIT) conveys information relating to the physical organisation of the multiplexes carried via a given network.
+	PIDSDT  uint16 = 0x11   // Service Description Table (SDT) and Bouquet Association Table (BAT) describe the services of the system and their grouping.
+	PIDEIT  uint16 = 0x12   // Event Information Table (EIT) contains data concerning events or programmes such as event name, start time, duration, etc.
+	PIDTDT  uint16 = 0x14   // Time and Date Table (TDT) and Time Offset Table (TOT) carry the UTC time and date, and the local time offset.
 	PIDNull uint16 = 0x1fff // Null Packet (used for fixed bandwidth padding)
 )
 
 // DemuxerData represents a data parsed by Demuxer
 type DemuxerData struct {
-	EIT         *EITData
-	FirstPacket *Packet
-	NIT         *NITData
-	PAT         *PATData
-	PES         *PESData
-	PID         uint16
-	PMT         *PMTData
-	SDT         *SDTData
-	TOT         *TOTData
+	AdaptationField *AdaptationFieldData
+	BAT             *BATData
+	CAT             *CATData
+	DIT             *DITData
+	EIT             *EITData
+	FirstPacket     *Packet
+	NIT             *NITData
+	PAT             *PATData
+	PES             *PESData
+	PID             uint16
+	PMT             *PMTData
+	RST             *RSTData
+	SCTE35          *SCTE35Data
+	SDT             *SDTData
+	SIT             *SITData
+	TDT             *TDTData
+	TOT             *TOTData
+	TableChange     *PSITableChange // Only set when the PSI table changes option is enabled
+	Unverified      bool            // Whether the PSI table CRC32 is invalid. Only set when the invalid PSI CRC32 tolerance option is enabled.
+}
+
+// AdaptationFieldData represents the adaptation field of a packet without payload, such as packets carrying
+// only the PCR
+type AdaptationFieldData struct {
+	AdaptationField *PacketAdaptationField
+	Offset          int64 // Offset of the packet in bytes since the beginning of the stream
 }
 
 // MuxerData represents a data to be written by Muxer
+// It carries either a PES packet or sections
 type MuxerData struct {
 	PID             uint16
 	AdaptationField *PacketAdaptationField
 	PES             *PESData
+	PrivateSections []*PrivateSection
+	Sections        []*PSISection
 }
 
 // parseData parses a payload spanning over multiple packets and returns a set of data
-func parseData(ps []*Packet, prs PacketsParser, pm *programMap) (ds []*DemuxerData, err error) {
+// If a PSI table assembler is provided, PSI tables are only returned once all their sections have been received
+// If a PSI table tracker is provided, PSI tables are only returned when they have changed
+// If invalidCRC32 is not nil, PSI sections whose CRC32 is invalid are not considered as errors: they're provided to it
+// and marked as unverified instead
+func parseData(ps []*Packet, prs PacketsParser, pm *programMap, a *psiTableAssembler, t *psiTableTracker, invalidCRC32 func(pid uint16, s *PSISection)) (ds []*DemuxerData, err error) {
 	// Use custom parser first
 	if prs != nil {
 		var skip bool
@@ -76,19 +104,51 @@ func parseData(ps []*Packet, prs PacketsParser, pm *programMap) (ds []*DemuxerDa
 	}
 
 	// Parse payload
-	if pid == PIDCAT {
-		// Information in a CAT payload is private and dependent on the CA system. Use the PacketsParser
-		// to parse this type of payload
-	} else if isPSIPayload(pid, pm) {
+	if isPSIPayload(pid, pm) {
 		// Parse PSI data
 		var psiData *PSIData
-		if psiData, err = parsePSIData(i); err != nil {
+		if psiData, err = parsePSIData(i, invalidCRC32 != nil); err != nil {
 			err = fmt.Errorf("astits: parsing PSI data failed: %w", err)
 			return
 		}
 
+		// Report invalid CRC32s
+		if invalidCRC32 != nil {
+			for _, s := range psiData.Sections {
+				if s.Unverified {
+					invalidCRC32(pid, s)
+				}
+			}
+		}
+
 		// Append data
-		ds = psiData.toData(fp, pid)
+		if a == nil && t == nil {
+			ds = psiData.toData(fp, pid)
+			return
+		}
+		for _, s := range psiData.Sections {
+			// Assemble table
+			sfp := fp
+			if a != nil {
+				if s, sfp = a.add(s, fp, pid); s == nil {
+					continue
+				}
+			}
+
+			// Track changes
+			var c *PSITableChange
+			if t != nil && s.Syntax != nil && s.Syntax.Data != nil {
+				if c = t.update(s, pid); c == nil {
+					continue
+				}
+			}
+
+			// Append data
+			if d := s.toData(sfp, pid); d != nil {
+				d.TableChange = c
+				ds = append(ds, d)
+			}
+		}
 	} else if isPESPayload(payload.s) {
 		// Parse PES data
 		var pesData *PESData
@@ -112,7 +172,9 @@ func parseData(ps []*Packet, prs PacketsParser, pm *programMap) (ds []*DemuxerDa
 // isPSIPayload checks whether the payload is a PSI one
 func isPSIPayload(pid uint16, pm *programMap) bool {
 	return pid == PIDPAT || // PAT
-		pm.existsUnlocked(pid) || // PMT
+		pid == PIDCAT || // CAT
+		(pm != nil && pm.existsUnlocked(pid)) || // PMT
+		(pm != nil && pm.isSCTE35Unlocked(pid)) || // SCTE-35
 		((pid >= 0x10 && pid <= 0x14) || (pid >= 0x1e && pid <= 0x1f)) //DVB
 }
 
@@ -127,8 +189,36 @@ func isPESPayload(i []byte) bool {
 	return uint32(i[0])<<16|uint32(i[1])<<8|uint32(i[2]) == 1
 }
 
-// isPSIComplete checks whether we have sufficient amount of packets to parse PSI
-func isPSIComplete(ps []*Packet) bool {
+// isPESComplete checks whether the PES packet length is known and every byte of the PES packet has been received
+// The PES packet length is usually unknown for video streams
+func isPESComplete(ps []*Packet) bool {
+	// First packet must start the PES packet
+	if len(ps) == 0 || !ps[0].Header.PayloadUnitStartIndicator {
+		return false
+	}
+
+	// Get PES packet length
+	b := ps[0].Payload
+	if len(b) < pesHeaderLength || !isPESPayload(b) {
+		return false
+	}
+	l := int(binary.BigEndian.Uint16(b[4:6]))
+	if l == 0 {
+		return false
+	}
+
+	// Get payload length
+	var n int
+	for _, p := range ps {
+		n += len(p.Payload)
+	}
+	return n >= pesHeaderLength+l
+}
+
+// psiCompleteLength returns the length of the beginning of the PSI payload made of the packets that only contains
+// complete sections, which is 0 if no section is complete yet, and whether the whole payload is complete, which is the
+// case when the last complete section ends the payload or is followed by stuffing bytes
+func psiCompleteLength(ps []*Packet) (n int, complete bool) {
 	// Get payload length
 	var l int
 	for _, p := range ps {
@@ -151,7 +241,7 @@ func isPSIComplete(ps []*Packet) bool {
 	// Get next byte
 	b, err := i.NextByte()
 	if err != nil {
-		return false
+		return
 	}
 
 	// Pointer filler bytes
@@ -162,23 +252,27 @@ func isPSIComplete(ps []*Packet) bool {
 		// Get PSI table ID
 		b, err = i.NextByte()
 		if err != nil {
-			return false
+			return
 		}
 
 		// Check whether we need to stop the parsing
 		if shouldStopPSIParsing(PSITableID(b)) {
-			break
+			return l, true
 		}
 
 		// Get PSI section length
 		var bs []byte
 		bs, err = i.NextBytesNoCopy(2)
 		if err != nil {
-			return false
+			return
 		}
 
+		// Section is not complete
 		i.Skip(int(binary.BigEndian.Uint16(bs) & 0x0fff))
+		if i.Offset() > i.Len() {
+			return
+		}
+		n = i.Offset()
 	}
-
-	return i.Len() >= i.Offset()
+	return n, n == l
 }
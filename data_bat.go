@@ -0,0 +1,74 @@
+package astits
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astikit"
+)
+
+// BATData represents a BAT data
+// Page: 30 | Chapter: 5.2.2 | Link: https://www.dvb.org/resources/public/standards/a38_dvb-si_specification.pdf
+// (barbashov) the link above can be broken, alternative: https://dvb.org/wp-content/uploads/2019/12/a038_tm1217r37_en300468v1_17_1_-_rev-134_-_si_specification.pdf
+type BATData struct {
+	BouquetDescriptors []*Descriptor
+	BouquetID          uint16
+	TransportStreams   []*BATDataTransportStream
+}
+
+// BATDataTransportStream represents a BAT data transport stream
+type BATDataTransportStream struct {
+	OriginalNetworkID    uint16
+	TransportDescriptors []*Descriptor
+	TransportStreamID    uint16
+}
+
+// parseBATSection parses a BAT section
+func parseBATSection(i *astikit.BytesIterator, tableIDExtension uint16) (d *BATData, err error) {
+	// Create data
+	d = &BATData{BouquetID: tableIDExtension}
+
+	// Bouquet descriptors
+	if d.BouquetDescriptors, err = parseDescriptors(i); err != nil {
+		err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+		return
+	}
+
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(2); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Transport stream loop length
+	transportStreamLoopLength := int(uint16(bs[0]&0xf)<<8 | uint16(bs[1]))
+
+	// Transport stream loop
+	offsetEnd := i.Offset() + transportStreamLoopLength
+	for i.Offset() < offsetEnd {
+		// Create transport stream
+		ts := &BATDataTransportStream{}
+
+		// Get next bytes
+		if bs, err = i.NextBytesNoCopy(4); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+
+		// Transport stream ID
+		ts.TransportStreamID = uint16(bs[0])<<8 | uint16(bs[1])
+
+		// Original network ID
+		ts.OriginalNetworkID = uint16(bs[2])<<8 | uint16(bs[3])
+
+		// Transport descriptors
+		if ts.TransportDescriptors, err = parseDescriptors(i); err != nil {
+			err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+			return
+		}
+
+		// Append transport stream
+		d.TransportStreams = append(d.TransportStreams, ts)
+	}
+	return
+}
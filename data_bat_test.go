@@ -0,0 +1,58 @@
+package astits
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+var bat = &BATData{
+	BouquetDescriptors: descriptors,
+	BouquetID:          1,
+	TransportStreams: []*BATDataTransportStream{{
+		OriginalNetworkID:    3,
+		TransportDescriptors: descriptors,
+		TransportStreamID:    2,
+	}},
+}
+
+func batBytes() []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write("0000")         // Reserved for future use
+	descriptorsBytes(w)     // Bouquet descriptors
+	w.Write("0000")         // Reserved for future use
+	w.Write("000000001001") // Transport stream loop length
+	w.Write(uint16(2))      // Transport stream #1 id
+	w.Write(uint16(3))      // Transport stream #1 original network id
+	w.Write("0000")         // Transport stream #1 reserved for future use
+	descriptorsBytes(w)     // Transport stream #1 descriptors
+	return buf.Bytes()
+}
+
+func TestParseBATSection(t *testing.T) {
+	var b = batBytes()
+	d, err := parseBATSection(astikit.NewBytesIterator(b), uint16(1))
+	assert.Equal(t, d, bat)
+	assert.NoError(t, err)
+}
+
+func TestParseBATPSIData(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write(uint8(PSITableIDBAT))          // BAT table ID
+	w.Write("1")                           // BAT syntax section indicator
+	w.Write("1")                           // BAT reserved for future use
+	w.Write("11")                          // BAT reserved
+	w.Write("000000011001")                // BAT section length
+	w.Write(psiSectionSyntaxHeaderBytes()) // BAT syntax section header
+	w.Write(batBytes())                    // BAT data
+	w.Write(computeCRC32(buf.Bytes()))     // BAT CRC32
+
+	d, err := parsePSIData(astikit.NewBytesIterator(append([]byte{0}, buf.Bytes()...)), false)
+	assert.NoError(t, err)
+	p := &Packet{}
+	assert.Equal(t, []*DemuxerData{{BAT: bat, FirstPacket: p, PID: PIDSDT}}, d.toData(p, PIDSDT))
+}
@@ -0,0 +1,35 @@
+package astits
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astikit"
+)
+
+// CATData represents a CAT data
+// Its CA descriptors list the CA systems of the transport stream and the PIDs of their EMMs
+// Page: 52 | Chapter: 2.4.4.6 | Link: https://www.itu.int/rec/T-REC-H.222.0
+type CATData struct {
+	Descriptors []*Descriptor
+}
+
+// parseCATSection parses a CAT section
+func parseCATSection(i *astikit.BytesIterator, offsetSectionsEnd int) (d *CATData, err error) {
+	// Create data
+	d = &CATData{}
+
+	// Descriptors
+	if d.Descriptors, err = parseDescriptorsLoop(i, offsetSectionsEnd-i.Offset()); err != nil {
+		err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+		return
+	}
+	return
+}
+
+func calcCATSectionLength(d *CATData) uint16 {
+	return calcDescriptorsLength(d.Descriptors)
+}
+
+func writeCATSection(w *astikit.BitsWriter, d *CATData) (int, error) {
+	return writeDescriptors(w, d.Descriptors)
+}
@@ -0,0 +1,38 @@
+package astits
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+var cat = &CATData{Descriptors: []*Descriptor{{
+	CA: &DescriptorCA{
+		CAPID:       0x1ff,
+		CASystemID:  0x500,
+		PrivateData: []byte{0x1},
+	},
+	Length: 5,
+	Tag:    DescriptorTagCA,
+}}}
+
+func catBytes() []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write(uint8(DescriptorTagCA)) // CA descriptor tag
+	w.Write(uint8(5))               // CA descriptor length
+	w.Write(uint16(0x500))          // CA system ID
+	w.Write("111")                  // Reserved
+	w.Write("0000111111111")        // CA PID
+	w.Write(uint8(0x1))             // Private data
+	return buf.Bytes()
+}
+
+func TestParseCATSection(t *testing.T) {
+	var b = catBytes()
+	d, err := parseCATSection(astikit.NewBytesIterator(b), len(b))
+	assert.NoError(t, err)
+	assert.Equal(t, cat, d)
+}
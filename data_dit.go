@@ -0,0 +1,28 @@
+package astits
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astikit"
+)
+
+// DITData represents a DIT data, which is inserted in partial transport streams where they may be discontinuous
+// Page: 12 | Chapter: 7.1.1 | Link: https://www.dvb.org/resources/public/standards/a38_dvb-si_specification.pdf
+// (barbashov) the link above can be broken, alternative: https://dvb.org/wp-content/uploads/2019/12/a038_tm1217r37_en300468v1_17_1_-_rev-134_-_si_specification.pdf
+type DITData struct {
+	TransitionFlag bool // Whether the transition is due to a change of the originating source, rather than a change of the selection only
+}
+
+// parseDITSection parses a DIT section
+func parseDITSection(i *astikit.BytesIterator) (d *DITData, err error) {
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Create data
+	d = &DITData{TransitionFlag: b&0x80 > 0}
+	return
+}
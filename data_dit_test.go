@@ -0,0 +1,18 @@
+package astits
+
+import (
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDITSection(t *testing.T) {
+	d, err := parseDITSection(astikit.NewBytesIterator([]byte{0xff}))
+	assert.Equal(t, d, &DITData{TransitionFlag: true})
+	assert.NoError(t, err)
+
+	d, err = parseDITSection(astikit.NewBytesIterator([]byte{0x7f}))
+	assert.Equal(t, d, &DITData{})
+	assert.NoError(t, err)
+}
@@ -1,12 +1,34 @@
 package astits
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/asticode/go-astikit"
 )
 
+// EIT table IDs
+const (
+	eitTableIDPresentFollowingActual PSITableID = 0x4e
+	eitTableIDPresentFollowingOther  PSITableID = 0x4f
+	eitTableIDScheduleActual         PSITableID = 0x50
+	eitTableIDScheduleOther          PSITableID = 0x60
+)
+
+// EIT sections layout
+const (
+	eitScheduleSectionsPerSegment = 8
+	eitScheduleSegmentDuration    = 3 * time.Hour
+	eitScheduleSegmentsPerTable   = 32
+	eitScheduleTablesCount        = 16
+	eitSectionMaxLength           = 4093
+)
+
+// ErrEITSegmentTooLarge is returned when the events of an EIT schedule segment don't fit in 8 sections
+var ErrEITSegmentTooLarge = errors.New("astits: EIT schedule segment doesn't fit in 8 sections")
+
 // EITData represents an EIT data
 // Page: 36 | Chapter: 5.2.4 | Link: https://www.dvb.org/resources/public/standards/a38_dvb-si_specification.pdf
 // (barbashov) the link above can be broken, alternative: https://dvb.org/wp-content/uploads/2019/12/a038_tm1217r37_en300468v1_17_1_-_rev-134_-_si_specification.pdf
@@ -122,3 +144,179 @@ func parseEITSection(i *astikit.BytesIterator, offsetSectionsEnd int, tableIDExt
 	}
 	return
 }
+
+func calcEITSectionLength(d *EITData) uint16 {
+	ret := uint16(6) // transport_stream_id, original_network_id, segment_last_section_number, last_table_id
+	for _, e := range d.Events {
+		ret += calcEITEventLength(e)
+	}
+	return ret
+}
+
+func calcEITEventLength(e *EITDataEvent) uint16 {
+	return 12 + calcDescriptorsLength(e.Descriptors) // event_id, start_time, duration, running_status, free_CA_mode, descriptors_loop_length
+}
+
+func writeEITSection(w *astikit.BitsWriter, d *EITData) (int, error) {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(d.TransportStreamID)
+	b.Write(d.OriginalNetworkID)
+	b.Write(d.SegmentLastSectionNumber)
+	b.Write(d.LastTableID)
+	bytesWritten := 6
+
+	for _, e := range d.Events {
+		b.Write(e.EventID)
+		bytesWritten += 2
+
+		if err := b.Err(); err != nil {
+			return 0, err
+		}
+
+		n, err := writeDVBTime(w, e.StartTime)
+		if err != nil {
+			return 0, err
+		}
+		bytesWritten += n
+
+		if n, err = writeDVBDurationSeconds(w, e.Duration); err != nil {
+			return 0, err
+		}
+		bytesWritten += n
+
+		b.WriteN(e.RunningStatus, 3)
+		b.Write(e.HasFreeCSAMode)
+		b.WriteN(calcDescriptorsLength(e.Descriptors), 12)
+		bytesWritten += 2
+
+		if err = b.Err(); err != nil {
+			return 0, err
+		}
+
+		if n, err = writeDescriptors(w, e.Descriptors); err != nil {
+			return 0, err
+		}
+		bytesWritten += n
+	}
+
+	return bytesWritten, b.Err()
+}
+
+// newEITPresentFollowingSections creates the EIT present/following sections of a service: the first event of d is
+// the present event and the second one is the following event. A missing event results in an empty section.
+func newEITPresentFollowingSections(d *EITData, actual bool, versionNumber uint8) ([]*PSISection, error) {
+	tableID := eitTableIDPresentFollowingOther
+	if actual {
+		tableID = eitTableIDPresentFollowingActual
+	}
+
+	var ss []*PSISection
+	for i := 0; i < 2; i++ {
+		sd := *d
+		sd.Events = nil
+		if i < len(d.Events) {
+			sd.Events = d.Events[i : i+1]
+		}
+		sd.LastTableID = uint8(tableID)
+		sd.SegmentLastSectionNumber = 1
+
+		s := newPSISection(tableID, d.ServiceID, versionNumber, &PSISectionSyntaxData{EIT: &sd})
+		if s.Header.SectionLength > eitSectionMaxLength {
+			return nil, ErrPSISectionTooLarge
+		}
+		ss = append(ss, s)
+	}
+	setPSISectionNumbers(ss)
+	return ss, nil
+}
+
+// newEITScheduleSections spreads the events of a service over EIT schedule sections
+// Page: 27 | Chapter: 5.1.4 | Link: https://www.dvb.org/resources/public/standards/a38_dvb-si_specification.pdf
+// Events are put in segments of 3 hours based on their start time, the first segment starting at midnight UTC of the
+// day of now. Each table ID covers 32 segments, i.e. 4 days, and each segment is made of up to 8 sections. Events that
+// started before the first segment and are still running are put in the first segment, and events that are over or
+// that start after the last segment of the last table ID are dropped. Empty segments are made of an empty section.
+func newEITScheduleSections(d *EITData, actual bool, now time.Time, versionNumber uint8) ([]*PSISection, error) {
+	// Put events in segments
+	start := now.UTC().Truncate(24 * time.Hour)
+	segments := make(map[int][]*EITDataEvent)
+	var lastSegment int
+	for _, e := range d.Events {
+		idx := int(e.StartTime.Sub(start) / eitScheduleSegmentDuration)
+		if e.StartTime.Before(start) {
+			if !e.StartTime.Add(e.Duration).After(start) {
+				continue
+			}
+			idx = 0
+		}
+		if idx >= eitScheduleTablesCount*eitScheduleSegmentsPerTable {
+			continue
+		}
+		segments[idx] = append(segments[idx], e)
+		if idx > lastSegment {
+			lastSegment = idx
+		}
+	}
+
+	// Get table IDs
+	firstTableID := eitTableIDScheduleOther
+	if actual {
+		firstTableID = eitTableIDScheduleActual
+	}
+	lastTableID := firstTableID + PSITableID(lastSegment/eitScheduleSegmentsPerTable)
+
+	// Loop through tables
+	var ss []*PSISection
+	for tableID := firstTableID; tableID <= lastTableID; tableID++ {
+		// Get last segment of the table
+		firstSegment := int(tableID-firstTableID) * eitScheduleSegmentsPerTable
+		lastTableSegment := firstSegment
+		for idx := firstSegment; idx < firstSegment+eitScheduleSegmentsPerTable; idx++ {
+			if len(segments[idx]) > 0 {
+				lastTableSegment = idx
+			}
+		}
+
+		// Loop through segments
+		var ts []*PSISection
+		for idx := firstSegment; idx <= lastTableSegment; idx++ {
+			// Split events over sections
+			es := segments[idx]
+			sort.SliceStable(es, func(i, j int) bool { return es[i].StartTime.Before(es[j].StartTime) })
+			var lengths []int
+			for _, e := range es {
+				lengths = append(lengths, int(calcEITEventLength(e)))
+			}
+			base := int(calcEITSectionLength(&EITData{}))
+			counts, err := splitPSISectionItems(lengths, base, base, eitSectionMaxLength)
+			if err != nil {
+				return nil, err
+			}
+			if len(counts) > eitScheduleSectionsPerSegment {
+				return nil, ErrEITSegmentTooLarge
+			}
+
+			// Create sections
+			sectionNumber := (idx - firstSegment) * eitScheduleSectionsPerSegment
+			for i, c := range counts {
+				sd := *d
+				sd.Events = es[:c]
+				sd.LastTableID = uint8(lastTableID)
+				sd.SegmentLastSectionNumber = uint8(sectionNumber + len(counts) - 1)
+				es = es[c:]
+
+				s := newPSISection(tableID, d.ServiceID, versionNumber, &PSISectionSyntaxData{EIT: &sd})
+				s.Syntax.Header.SectionNumber = uint8(sectionNumber + i)
+				ts = append(ts, s)
+			}
+		}
+
+		// Update last section number
+		for _, s := range ts {
+			s.Syntax.Header.LastSectionNumber = ts[len(ts)-1].Syntax.Header.SectionNumber
+		}
+		ss = append(ss, ts...)
+	}
+	return ss, nil
+}
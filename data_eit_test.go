@@ -3,6 +3,7 @@ package astits
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/asticode/go-astikit"
 	"github.com/stretchr/testify/assert"
@@ -46,3 +47,93 @@ func TestParseEITSection(t *testing.T) {
 	assert.Equal(t, d, eit)
 	assert.NoError(t, err)
 }
+
+func TestNewEITPresentFollowingSections(t *testing.T) {
+	ss, err := newEITPresentFollowingSections(&EITData{Events: eit.Events, ServiceID: 1}, true, 2)
+	assert.NoError(t, err)
+	assert.Len(t, ss, 2)
+	for i, s := range ss {
+		assert.Equal(t, eitTableIDPresentFollowingActual, s.Header.TableID)
+		assert.Equal(t, uint8(i), s.Syntax.Header.SectionNumber)
+		assert.Equal(t, uint8(1), s.Syntax.Header.LastSectionNumber)
+		assert.Equal(t, uint16(1), s.Syntax.Header.TableIDExtension)
+		assert.Equal(t, uint8(2), s.Syntax.Header.VersionNumber)
+		assert.Equal(t, uint8(1), s.Syntax.Data.EIT.SegmentLastSectionNumber)
+		assert.Equal(t, uint8(eitTableIDPresentFollowingActual), s.Syntax.Data.EIT.LastTableID)
+	}
+	assert.Equal(t, eit.Events, ss[0].Syntax.Data.EIT.Events)
+	assert.Empty(t, ss[1].Syntax.Data.EIT.Events)
+}
+
+func TestNewEITScheduleSections(t *testing.T) {
+	now := time.Date(2024, 5, 10, 13, 0, 0, 0, time.UTC)
+	midnight := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	e := func(id uint16, start time.Time) *EITDataEvent {
+		return &EITDataEvent{Duration: 2 * time.Hour, EventID: id, StartTime: start}
+	}
+	ss, err := newEITScheduleSections(&EITData{Events: []*EITDataEvent{
+		e(1, midnight.Add(-time.Hour)),               // Still running: segment 0
+		e(2, midnight.Add(-3*time.Hour)),             // Over
+		e(3, midnight.Add(14*time.Hour)),             // Segment 4
+		e(4, midnight.Add(10*time.Hour)),             // Segment 3
+		e(5, midnight.Add(13*time.Hour)),             // Segment 4
+		e(6, midnight.Add(4*24*time.Hour+time.Hour)), // Second table, segment 0
+		e(7, midnight.Add(70*24*time.Hour)),          // Too late
+	}, ServiceID: 1}, false, now, 0)
+	assert.NoError(t, err)
+
+	type section struct {
+		events                   []uint16
+		lastSectionNumber        uint8
+		sectionNumber            uint8
+		segmentLastSectionNumber uint8
+		tableID                  PSITableID
+	}
+	var actual []section
+	for _, s := range ss {
+		assert.Equal(t, uint8(0x61), s.Syntax.Data.EIT.LastTableID)
+		v := section{
+			lastSectionNumber:        s.Syntax.Header.LastSectionNumber,
+			sectionNumber:            s.Syntax.Header.SectionNumber,
+			segmentLastSectionNumber: s.Syntax.Data.EIT.SegmentLastSectionNumber,
+			tableID:                  s.Header.TableID,
+		}
+		for _, e := range s.Syntax.Data.EIT.Events {
+			v.events = append(v.events, e.EventID)
+		}
+		actual = append(actual, v)
+	}
+	assert.Equal(t, []section{
+		{events: []uint16{1}, lastSectionNumber: 32, sectionNumber: 0, segmentLastSectionNumber: 0, tableID: 0x60},
+		{lastSectionNumber: 32, sectionNumber: 8, segmentLastSectionNumber: 8, tableID: 0x60},
+		{lastSectionNumber: 32, sectionNumber: 16, segmentLastSectionNumber: 16, tableID: 0x60},
+		{events: []uint16{4}, lastSectionNumber: 32, sectionNumber: 24, segmentLastSectionNumber: 24, tableID: 0x60},
+		{events: []uint16{5, 3}, lastSectionNumber: 32, sectionNumber: 32, segmentLastSectionNumber: 32, tableID: 0x60},
+		{events: []uint16{6}, lastSectionNumber: 0, sectionNumber: 0, segmentLastSectionNumber: 0, tableID: 0x61},
+	}, actual)
+
+	// Events of a segment are split over several sections
+	ds := []*Descriptor{{Length: 250, Tag: 0x80, UserDefined: make([]byte, 250)}}
+	var es []*EITDataEvent
+	for i := 0; i < 16; i++ {
+		es = append(es, &EITDataEvent{Descriptors: ds, EventID: uint16(i), StartTime: now})
+	}
+	ss, err = newEITScheduleSections(&EITData{Events: es}, true, now, 0)
+	assert.NoError(t, err)
+	assert.Len(t, ss, 6)
+	for i, s := range ss[4:] {
+		assert.Equal(t, eitTableIDScheduleActual, s.Header.TableID)
+		assert.Equal(t, uint8(32+i), s.Syntax.Header.SectionNumber)
+		assert.Equal(t, uint8(33), s.Syntax.Header.LastSectionNumber)
+		assert.Equal(t, uint8(33), s.Syntax.Data.EIT.SegmentLastSectionNumber)
+		assert.True(t, s.Header.SectionLength <= eitSectionMaxLength)
+	}
+	assert.Len(t, ss[4].Syntax.Data.EIT.Events, 15)
+
+	// Events of a segment don't fit in 8 sections
+	for i := 0; i < 8*15; i++ {
+		es = append(es, &EITDataEvent{Descriptors: ds, StartTime: now})
+	}
+	_, err = newEITScheduleSections(&EITData{Events: es}, true, now, 0)
+	assert.Equal(t, ErrEITSegmentTooLarge, err)
+}
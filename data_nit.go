@@ -78,3 +78,78 @@ func parseNITSection(i *astikit.BytesIterator, tableIDExtension uint16) (d *NITD
 	}
 	return
 }
+
+func calcNITSectionLength(d *NITData) uint16 {
+	ret := uint16(4) // network_descriptors_length, transport_stream_loop_length
+	ret += calcDescriptorsLength(d.NetworkDescriptors)
+	for _, ts := range d.TransportStreams {
+		ret += calcNITTransportStreamLength(ts)
+	}
+	return ret
+}
+
+func calcNITTransportStreamLength(ts *NITDataTransportStream) uint16 {
+	return 6 + calcDescriptorsLength(ts.TransportDescriptors) // transport_stream_id, original_network_id, transport_descriptors_length
+}
+
+func writeNITSection(w *astikit.BitsWriter, d *NITData) (int, error) {
+	bytesWritten, err := writeDescriptorsWithLength(w, d.NetworkDescriptors)
+	if err != nil {
+		return 0, err
+	}
+
+	var transportStreamLoopLength uint16
+	for _, ts := range d.TransportStreams {
+		transportStreamLoopLength += calcNITTransportStreamLength(ts)
+	}
+
+	b := astikit.NewBitsWriterBatch(w)
+	b.WriteN(uint8(0xff), 4) // reserved_future_use
+	b.WriteN(transportStreamLoopLength, 12)
+	bytesWritten += 2
+
+	for _, ts := range d.TransportStreams {
+		b.Write(ts.TransportStreamID)
+		b.Write(ts.OriginalNetworkID)
+		bytesWritten += 4
+
+		if err = b.Err(); err != nil {
+			return 0, err
+		}
+
+		n, err := writeDescriptorsWithLength(w, ts.TransportDescriptors)
+		if err != nil {
+			return 0, err
+		}
+		bytesWritten += n
+	}
+
+	return bytesWritten, b.Err()
+}
+
+// newNITSections splits a NIT over as many sections as needed
+// Network descriptors are only written in the first section
+func newNITSections(d *NITData, tableID PSITableID, versionNumber uint8) ([]*PSISection, error) {
+	var lengths []int
+	for _, ts := range d.TransportStreams {
+		lengths = append(lengths, int(calcNITTransportStreamLength(ts)))
+	}
+	base := int(calcNITSectionLength(&NITData{}))
+	counts, err := splitPSISectionItems(lengths, base+int(calcDescriptorsLength(d.NetworkDescriptors)), base, psiSectionMaxLength)
+	if err != nil {
+		return nil, err
+	}
+
+	var ss []*PSISection
+	transportStreams := d.TransportStreams
+	for i, c := range counts {
+		sd := &NITData{NetworkID: d.NetworkID, TransportStreams: transportStreams[:c]}
+		if i == 0 {
+			sd.NetworkDescriptors = d.NetworkDescriptors
+		}
+		transportStreams = transportStreams[c:]
+		ss = append(ss, newPSISection(tableID, d.NetworkID, versionNumber, &PSISectionSyntaxData{NIT: sd}))
+	}
+	setPSISectionNumbers(ss)
+	return ss, nil
+}
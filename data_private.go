@@ -0,0 +1,79 @@
+package astits
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astikit"
+)
+
+// privateSectionMaxLength is the max section length of private sections
+const privateSectionMaxLength = 4093
+
+// PrivateSection represents a private section whose data is written as is, such as a SCTE-35 cue, an AIT or a data
+// carousel section
+// Page: 71 | Chapter: 2.4.4.10 | Link: https://www.itu.int/rec/T-REC-H.222.0
+type PrivateSection struct {
+	Data             []byte // private_data_bytes
+	HasCRC32         bool   // Whether a CRC32 is written after the data of short-form sections, as SCTE-35 does. Long-form sections always have one.
+	PrivateIndicator bool
+	// Syntax header of long-form sections, whose section_syntax_indicator is set. Short-form sections don't have one.
+	SyntaxHeader *PSISectionSyntaxHeader
+	TableID      PSITableID
+}
+
+func (s *PrivateSection) hasCRC32() bool {
+	return s.HasCRC32 || s.SyntaxHeader != nil
+}
+
+func calcPrivateSectionLength(s *PrivateSection) int {
+	ret := len(s.Data)
+	if s.SyntaxHeader != nil {
+		ret += 5
+	}
+	if s.hasCRC32() {
+		ret += 4
+	}
+	return ret
+}
+
+func writePrivateSection(w *astikit.BitsWriter, s *PrivateSection) (int, error) {
+	sectionLength := calcPrivateSectionLength(s)
+	if sectionLength > privateSectionMaxLength {
+		return 0, fmt.Errorf("astits: private section of table %#x has a length of %d: %w", uint16(s.TableID), sectionLength, ErrPSISectionTooLarge)
+	}
+
+	b := astikit.NewBitsWriterBatch(w)
+
+	sectionCRC32 := crc32Polynomial
+	if s.hasCRC32() {
+		w.SetWriteCallback(func(bs []byte) {
+			sectionCRC32 = updateCRC32(sectionCRC32, bs)
+		})
+		defer w.SetWriteCallback(nil)
+	}
+
+	b.Write(uint8(s.TableID))
+	b.Write(s.SyntaxHeader != nil)
+	b.Write(s.PrivateIndicator)
+	b.WriteN(uint8(0xff), 2)
+	b.WriteN(uint16(sectionLength), 12)
+	bytesWritten := 3
+
+	if s.SyntaxHeader != nil {
+		n, err := writePSISectionSyntaxHeader(w, s.SyntaxHeader)
+		if err != nil {
+			return 0, err
+		}
+		bytesWritten += n
+	}
+
+	b.Write(s.Data)
+	bytesWritten += len(s.Data)
+
+	if s.hasCRC32() {
+		b.Write(sectionCRC32)
+		bytesWritten += 4
+	}
+
+	return bytesWritten, b.Err()
+}
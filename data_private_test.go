@@ -0,0 +1,51 @@
+package astits
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrivateSection(t *testing.T) {
+	// Short form
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	n, err := writePrivateSection(w, &PrivateSection{Data: []byte("test"), TableID: 0xfc})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, n)
+	assert.Equal(t, []byte{0xfc, 0x30, 0x04, 't', 'e', 's', 't'}, buf.Bytes())
+
+	// Short form with CRC32
+	buf.Reset()
+	n, err = writePrivateSection(w, &PrivateSection{Data: []byte("test"), HasCRC32: true, TableID: 0xfc})
+	assert.NoError(t, err)
+	assert.Equal(t, 11, n)
+	assert.Equal(t, []byte{0xfc, 0x30, 0x08, 't', 'e', 's', 't'}, buf.Bytes()[:7])
+	assert.Equal(t, uint32(0), computeCRC32(buf.Bytes()))
+
+	// Long form
+	buf.Reset()
+	n, err = writePrivateSection(w, &PrivateSection{
+		Data:             []byte("test"),
+		PrivateIndicator: true,
+		SyntaxHeader: &PSISectionSyntaxHeader{
+			CurrentNextIndicator: true,
+			LastSectionNumber:    2,
+			SectionNumber:        1,
+			TableIDExtension:     0x1234,
+			VersionNumber:        3,
+		},
+		TableID: 0x74,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 16, n)
+	assert.Equal(t, []byte{0x74, 0xf0, 0x0d, 0x12, 0x34, 0xc7, 0x01, 0x02, 't', 'e', 's', 't'}, buf.Bytes()[:12])
+	assert.Equal(t, uint32(0), computeCRC32(buf.Bytes()))
+
+	// Too large
+	_, err = writePrivateSection(w, &PrivateSection{Data: make([]byte, privateSectionMaxLength+1), TableID: 0xfc})
+	assert.True(t, errors.Is(err, ErrPSISectionTooLarge))
+}
@@ -1,14 +1,22 @@
 package astits
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/asticode/go-astikit"
 )
 
+// Errors
+var (
+	ErrPSIInvalidCRC32    = errors.New("astits: invalid PSI CRC32")
+	ErrPSISectionTooLarge = errors.New("astits: data doesn't fit in a PSI section")
+)
+
 // PSI table IDs
 const (
 	PSITableTypeBAT     = "BAT"
+	PSITableTypeCAT     = "CAT"
 	PSITableTypeDIT     = "DIT"
 	PSITableTypeEIT     = "EIT"
 	PSITableTypeNIT     = "NIT"
@@ -16,6 +24,7 @@ const (
 	PSITableTypePAT     = "PAT"
 	PSITableTypePMT     = "PMT"
 	PSITableTypeRST     = "RST"
+	PSITableTypeSCTE35  = "SCTE35"
 	PSITableTypeSDT     = "SDT"
 	PSITableTypeSIT     = "SIT"
 	PSITableTypeST      = "ST"
@@ -28,6 +37,7 @@ type PSITableID uint16
 
 const (
 	PSITableIDPAT  PSITableID = 0x00
+	PSITableIDCAT  PSITableID = 0x01
 	PSITableIDPMT  PSITableID = 0x02
 	PSITableIDBAT  PSITableID = 0x4a
 	PSITableIDDIT  PSITableID = 0x7e
@@ -44,6 +54,9 @@ const (
 	PSITableIDSDTVariant2 PSITableID = 0x46
 	PSITableIDNITVariant1 PSITableID = 0x40
 	PSITableIDNITVariant2 PSITableID = 0x41
+
+	// SCTE-35 splice info sections are carried on the PIDs whose stream type is SCTE-35 in the PMT
+	PSITableIDSCTE35 PSITableID = 0xfc
 )
 
 // PSIData represents a PSI data
@@ -58,6 +71,9 @@ type PSISection struct {
 	CRC32  uint32 // A checksum of the entire table excluding the pointer field, pointer filler bytes and the trailing CRC32.
 	Header *PSISectionHeader
 	Syntax *PSISectionSyntax
+	// Whether the CRC32 doesn't match the computed CRC32. Such sections are only returned when the option to
+	// tolerate invalid PSI CRC32s is enabled.
+	Unverified bool
 }
 
 // PSISectionHeader represents a PSI section header
@@ -86,16 +102,25 @@ type PSISectionSyntaxHeader struct {
 
 // PSISectionSyntaxData represents a PSI section syntax data
 type PSISectionSyntaxData struct {
-	EIT *EITData
-	NIT *NITData
-	PAT *PATData
-	PMT *PMTData
-	SDT *SDTData
-	TOT *TOTData
+	BAT    *BATData
+	CAT    *CATData
+	DIT    *DITData
+	EIT    *EITData
+	NIT    *NITData
+	PAT    *PATData
+	PMT    *PMTData
+	RST    *RSTData
+	SCTE35 *SCTE35Data
+	SDT    *SDTData
+	SIT    *SITData
+	TDT    *TDTData
+	TOT    *TOTData
 }
 
 // parsePSIData parses a PSI data
-func parsePSIData(i *astikit.BytesIterator) (d *PSIData, err error) {
+// If tolerateInvalidCRC32 is true, sections whose CRC32 is invalid are returned and marked as unverified instead of
+// failing the parsing
+func parsePSIData(i *astikit.BytesIterator, tolerateInvalidCRC32 bool) (d *PSIData, err error) {
 	// Init data
 	d = &PSIData{}
 
@@ -116,7 +141,7 @@ func parsePSIData(i *astikit.BytesIterator) (d *PSIData, err error) {
 	var s *PSISection
 	var stop bool
 	for i.HasBytesLeft() && !stop {
-		if s, stop, err = parsePSISection(i); err != nil {
+		if s, stop, err = parsePSISection(i, tolerateInvalidCRC32); err != nil {
 			err = fmt.Errorf("astits: parsing PSI table failed: %w", err)
 			return
 		}
@@ -126,7 +151,7 @@ func parsePSIData(i *astikit.BytesIterator) (d *PSIData, err error) {
 }
 
 // parsePSISection parses a PSI section
-func parsePSISection(i *astikit.BytesIterator) (s *PSISection, stop bool, err error) {
+func parsePSISection(i *astikit.BytesIterator, tolerateInvalidCRC32 bool) (s *PSISection, stop bool, err error) {
 	// Init section
 	s = &PSISection{}
 
@@ -175,8 +200,12 @@ func parsePSISection(i *astikit.BytesIterator) (s *PSISection, stop bool, err er
 
 			// Check CRC32
 			if crc32 != s.CRC32 {
-				err = fmt.Errorf("astits: Table CRC32 %x != computed CRC32 %x", s.CRC32, crc32)
-				return
+				if tolerateInvalidCRC32 {
+					s.Unverified = true
+				} else {
+					err = fmt.Errorf("%w: table CRC32 %x != computed CRC32 %x", ErrPSIInvalidCRC32, s.CRC32, crc32)
+					return
+				}
 			}
 		}
 	}
@@ -260,6 +289,8 @@ func (t PSITableID) Type() string {
 	switch {
 	case t == PSITableIDBAT:
 		return PSITableTypeBAT
+	case t == PSITableIDCAT:
+		return PSITableTypeCAT
 	case t >= PSITableIDEITStart && t <= PSITableIDEITEnd:
 		return PSITableTypeEIT
 	case t == PSITableIDDIT:
@@ -274,6 +305,8 @@ func (t PSITableID) Type() string {
 		return PSITableTypePMT
 	case t == PSITableIDRST:
 		return PSITableTypeRST
+	case t == PSITableIDSCTE35:
+		return PSITableTypeSCTE35
 	case t == PSITableIDSDTVariant1, t == PSITableIDSDTVariant2:
 		return PSITableTypeSDT
 	case t == PSITableIDSIT:
@@ -292,7 +325,22 @@ func (t PSITableID) Type() string {
 // hasPSISyntaxHeader checks whether the section has a syntax header
 func (t PSITableID) hasPSISyntaxHeader() bool {
 	return t == PSITableIDPAT ||
+		t == PSITableIDBAT ||
+		t == PSITableIDCAT ||
+		t == PSITableIDPMT ||
+		t == PSITableIDSIT ||
+		t == PSITableIDNITVariant1 || t == PSITableIDNITVariant2 ||
+		t == PSITableIDSDTVariant1 || t == PSITableIDSDTVariant2 ||
+		(t >= PSITableIDEITStart && t <= PSITableIDEITEnd)
+}
+
+// isWritable checks whether sections of the table can be written
+func (t PSITableID) isWritable() bool {
+	return t == PSITableIDPAT ||
+		t == PSITableIDCAT ||
 		t == PSITableIDPMT ||
+		t == PSITableIDTDT ||
+		t == PSITableIDTOT ||
 		t == PSITableIDNITVariant1 || t == PSITableIDNITVariant2 ||
 		t == PSITableIDSDTVariant1 || t == PSITableIDSDTVariant2 ||
 		(t >= PSITableIDEITStart && t <= PSITableIDEITEnd)
@@ -301,7 +349,11 @@ func (t PSITableID) hasPSISyntaxHeader() bool {
 // hasCRC32 checks whether the table has a CRC32
 func (t PSITableID) hasCRC32() bool {
 	return t == PSITableIDPAT ||
+		t == PSITableIDBAT ||
+		t == PSITableIDCAT ||
 		t == PSITableIDPMT ||
+		t == PSITableIDSCTE35 ||
+		t == PSITableIDSIT ||
 		t == PSITableIDTOT ||
 		t == PSITableIDNITVariant1 || t == PSITableIDNITVariant2 ||
 		t == PSITableIDSDTVariant1 || t == PSITableIDSDTVariant2 ||
@@ -311,12 +363,14 @@ func (t PSITableID) hasCRC32() bool {
 func (t PSITableID) isUnknown() bool {
 	switch t {
 	case PSITableIDBAT,
+		PSITableIDCAT,
 		PSITableIDDIT,
 		PSITableIDNITVariant1, PSITableIDNITVariant2,
 		PSITableIDNull,
 		PSITableIDPAT,
 		PSITableIDPMT,
 		PSITableIDRST,
+		PSITableIDSCTE35,
 		PSITableIDSDTVariant1, PSITableIDSDTVariant2,
 		PSITableIDSIT,
 		PSITableIDST,
@@ -407,9 +461,20 @@ func parsePSISectionSyntaxData(i *astikit.BytesIterator, h *PSISectionHeader, sh
 	// Switch on table type
 	switch h.TableID {
 	case PSITableIDBAT:
-		// TODO Parse BAT
+		if d.BAT, err = parseBATSection(i, sh.TableIDExtension); err != nil {
+			err = fmt.Errorf("astits: parsing BAT section failed: %w", err)
+			return
+		}
+	case PSITableIDCAT:
+		if d.CAT, err = parseCATSection(i, offsetSectionsEnd); err != nil {
+			err = fmt.Errorf("astits: parsing CAT section failed: %w", err)
+			return
+		}
 	case PSITableIDDIT:
-		// TODO Parse DIT
+		if d.DIT, err = parseDITSection(i); err != nil {
+			err = fmt.Errorf("astits: parsing DIT section failed: %w", err)
+			return
+		}
 	case PSITableIDNITVariant1, PSITableIDNITVariant2:
 		if d.NIT, err = parseNITSection(i, sh.TableIDExtension); err != nil {
 			err = fmt.Errorf("astits: parsing NIT section failed: %w", err)
@@ -426,14 +491,25 @@ func parsePSISectionSyntaxData(i *astikit.BytesIterator, h *PSISectionHeader, sh
 			return
 		}
 	case PSITableIDRST:
-		// TODO Parse RST
+		if d.RST, err = parseRSTSection(i, offsetSectionsEnd); err != nil {
+			err = fmt.Errorf("astits: parsing RST section failed: %w", err)
+			return
+		}
+	case PSITableIDSCTE35:
+		if d.SCTE35, err = parseSCTE35Section(i, offsetSectionsEnd); err != nil {
+			err = fmt.Errorf("astits: parsing SCTE-35 section failed: %w", err)
+			return
+		}
 	case PSITableIDSDTVariant1, PSITableIDSDTVariant2:
 		if d.SDT, err = parseSDTSection(i, offsetSectionsEnd, sh.TableIDExtension); err != nil {
 			err = fmt.Errorf("astits: parsing PMT section failed: %w", err)
 			return
 		}
 	case PSITableIDSIT:
-		// TODO Parse SIT
+		if d.SIT, err = parseSITSection(i, offsetSectionsEnd); err != nil {
+			err = fmt.Errorf("astits: parsing SIT section failed: %w", err)
+			return
+		}
 	case PSITableIDST:
 		// TODO Parse ST
 	case PSITableIDTOT:
@@ -442,7 +518,10 @@ func parsePSISectionSyntaxData(i *astikit.BytesIterator, h *PSISectionHeader, sh
 			return
 		}
 	case PSITableIDTDT:
-		// TODO Parse TDT
+		if d.TDT, err = parseTDTSection(i); err != nil {
+			err = fmt.Errorf("astits: parsing TDT section failed: %w", err)
+			return
+		}
 	}
 
 	if h.TableID >= PSITableIDEITStart && h.TableID <= PSITableIDEITEnd {
@@ -460,29 +539,54 @@ func (d *PSIData) toData(firstPacket *Packet, pid uint16) (ds []*DemuxerData) {
 	// Loop through sections
 	ds = make([]*DemuxerData, 0, len(d.Sections))
 	for _, s := range d.Sections {
-		// No data
-		if s.Syntax == nil || s.Syntax.Data == nil {
-			continue
+		if v := s.toData(firstPacket, pid); v != nil {
+			ds = append(ds, v)
 		}
+	}
+	return
+}
 
-		// Switch on table type
-		switch s.Header.TableID {
-		case PSITableIDNITVariant1, PSITableIDNITVariant2:
-			ds = append(ds, &DemuxerData{FirstPacket: firstPacket, NIT: s.Syntax.Data.NIT, PID: pid})
-		case PSITableIDPAT:
-			ds = append(ds, &DemuxerData{FirstPacket: firstPacket, PAT: s.Syntax.Data.PAT, PID: pid})
-		case PSITableIDPMT:
-			ds = append(ds, &DemuxerData{FirstPacket: firstPacket, PID: pid, PMT: s.Syntax.Data.PMT})
-		case PSITableIDSDTVariant1, PSITableIDSDTVariant2:
-			ds = append(ds, &DemuxerData{FirstPacket: firstPacket, PID: pid, SDT: s.Syntax.Data.SDT})
-		case PSITableIDTOT:
-			ds = append(ds, &DemuxerData{FirstPacket: firstPacket, PID: pid, TOT: s.Syntax.Data.TOT})
-		}
-		if s.Header.TableID >= PSITableIDEITStart && s.Header.TableID <= PSITableIDEITEnd {
-			ds = append(ds, &DemuxerData{EIT: s.Syntax.Data.EIT, FirstPacket: firstPacket, PID: pid})
+// toData returns the DemuxerData of the PSI section or nil if the section has no data
+func (s *PSISection) toData(firstPacket *Packet, pid uint16) *DemuxerData {
+	// No data
+	if s.Syntax == nil || s.Syntax.Data == nil {
+		return nil
+	}
+
+	// Switch on table type
+	d := &DemuxerData{FirstPacket: firstPacket, PID: pid, Unverified: s.Unverified}
+	switch s.Header.TableID {
+	case PSITableIDBAT:
+		d.BAT = s.Syntax.Data.BAT
+	case PSITableIDCAT:
+		d.CAT = s.Syntax.Data.CAT
+	case PSITableIDDIT:
+		d.DIT = s.Syntax.Data.DIT
+	case PSITableIDNITVariant1, PSITableIDNITVariant2:
+		d.NIT = s.Syntax.Data.NIT
+	case PSITableIDPAT:
+		d.PAT = s.Syntax.Data.PAT
+	case PSITableIDPMT:
+		d.PMT = s.Syntax.Data.PMT
+	case PSITableIDRST:
+		d.RST = s.Syntax.Data.RST
+	case PSITableIDSCTE35:
+		d.SCTE35 = s.Syntax.Data.SCTE35
+	case PSITableIDSDTVariant1, PSITableIDSDTVariant2:
+		d.SDT = s.Syntax.Data.SDT
+	case PSITableIDSIT:
+		d.SIT = s.Syntax.Data.SIT
+	case PSITableIDTDT:
+		d.TDT = s.Syntax.Data.TDT
+	case PSITableIDTOT:
+		d.TOT = s.Syntax.Data.TOT
+	default:
+		if s.Header.TableID < PSITableIDEITStart || s.Header.TableID > PSITableIDEITEnd {
+			return nil
 		}
+		d.EIT = s.Syntax.Data.EIT
 	}
-	return
+	return d
 }
 
 func writePSIData(w *astikit.BitsWriter, d *PSIData) (int, error) {
@@ -516,10 +620,24 @@ func calcPSISectionLength(s *PSISection) uint16 {
 	}
 
 	switch s.Header.TableID {
+	case PSITableIDCAT:
+		ret += calcCATSectionLength(s.Syntax.Data.CAT)
+	case PSITableIDNITVariant1, PSITableIDNITVariant2:
+		ret += calcNITSectionLength(s.Syntax.Data.NIT)
 	case PSITableIDPAT:
 		ret += calcPATSectionLength(s.Syntax.Data.PAT)
 	case PSITableIDPMT:
 		ret += calcPMTSectionLength(s.Syntax.Data.PMT)
+	case PSITableIDSDTVariant1, PSITableIDSDTVariant2:
+		ret += calcSDTSectionLength(s.Syntax.Data.SDT)
+	case PSITableIDTDT:
+		ret += calcTDTSectionLength(s.Syntax.Data.TDT)
+	case PSITableIDTOT:
+		ret += calcTOTSectionLength(s.Syntax.Data.TOT)
+	default:
+		if s.Header.TableID >= PSITableIDEITStart && s.Header.TableID <= PSITableIDEITEnd {
+			ret += calcEITSectionLength(s.Syntax.Data.EIT)
+		}
 	}
 
 	if s.Header.TableID.hasCRC32() {
@@ -530,7 +648,7 @@ func calcPSISectionLength(s *PSISection) uint16 {
 }
 
 func writePSISection(w *astikit.BitsWriter, s *PSISection) (int, error) {
-	if s.Header.TableID != PSITableIDPAT && s.Header.TableID != PSITableIDPMT {
+	if !s.Header.TableID.isWritable() {
 		return 0, fmt.Errorf("writePSISection: table %s is not implemented", s.Header.TableID.Type())
 	}
 
@@ -553,7 +671,7 @@ func writePSISection(w *astikit.BitsWriter, s *PSISection) (int, error) {
 	b.WriteN(sectionLength, 12)
 	bytesWritten := 3
 
-	if s.Header.SectionLength > 0 {
+	if sectionLength > 0 {
 		n, err := writePSISectionSyntax(w, s)
 		if err != nil {
 			return 0, err
@@ -604,11 +722,77 @@ func writePSISectionSyntaxHeader(w *astikit.BitsWriter, h *PSISectionSyntaxHeade
 func writePSISectionSyntaxData(w *astikit.BitsWriter, d *PSISectionSyntaxData, tableID PSITableID) (int, error) {
 	switch tableID {
 	// TODO write other table types
+	case PSITableIDCAT:
+		return writeCATSection(w, d.CAT)
+	case PSITableIDNITVariant1, PSITableIDNITVariant2:
+		return writeNITSection(w, d.NIT)
 	case PSITableIDPAT:
 		return writePATSection(w, d.PAT)
 	case PSITableIDPMT:
 		return writePMTSection(w, d.PMT)
+	case PSITableIDSDTVariant1, PSITableIDSDTVariant2:
+		return writeSDTSection(w, d.SDT)
+	case PSITableIDTDT:
+		return writeTDTSection(w, d.TDT)
+	case PSITableIDTOT:
+		return writeTOTSection(w, d.TOT)
 	}
 
+	if tableID >= PSITableIDEITStart && tableID <= PSITableIDEITEnd {
+		return writeEITSection(w, d.EIT)
+	}
 	return 0, nil
 }
+
+// newPSISection creates a section with a syntax header whose section length is computed
+func newPSISection(tableID PSITableID, tableIDExtension uint16, versionNumber uint8, d *PSISectionSyntaxData) *PSISection {
+	s := &PSISection{
+		Header: &PSISectionHeader{
+			// The PAT, the CAT and the PMT set this to 0, DVB SI tables use it as reserved_future_use which is set to 1
+			PrivateBit:             tableID != PSITableIDPAT && tableID != PSITableIDCAT && tableID != PSITableIDPMT,
+			SectionSyntaxIndicator: true,
+			TableID:                tableID,
+			TableType:              tableID.Type(),
+		},
+		Syntax: &PSISectionSyntax{
+			Data: d,
+			Header: &PSISectionSyntaxHeader{
+				CurrentNextIndicator: true,
+				TableIDExtension:     tableIDExtension,
+				VersionNumber:        versionNumber,
+			},
+		},
+	}
+	s.Header.SectionLength = calcPSISectionLength(s)
+	return s
+}
+
+// setPSISectionNumbers sets the section number and the last section number of sections making up a table
+func setPSISectionNumbers(ss []*PSISection) {
+	for i, s := range ss {
+		s.Syntax.Header.SectionNumber = uint8(i)
+		s.Syntax.Header.LastSectionNumber = uint8(len(ss) - 1)
+	}
+}
+
+// splitPSISectionItems splits items over sections whose section length can't exceed maxLength, and returns the
+// number of items of each section. lengths are the lengths of the items, and firstLength and length are the lengths
+// of the data of the first and following sections without items. There's always at least one section.
+func splitPSISectionItems(lengths []int, firstLength, length, maxLength int) (counts []int, err error) {
+	// Syntax header and CRC32
+	maxLength -= 5 + 4
+
+	current, count := firstLength, 0
+	for _, l := range lengths {
+		if count > 0 && current+l > maxLength {
+			counts = append(counts, count)
+			current, count = length, 0
+		}
+		if current+l > maxLength {
+			return nil, ErrPSISectionTooLarge
+		}
+		current += l
+		count++
+	}
+	return append(counts, count), nil
+}
@@ -167,11 +167,19 @@ func TestParsePSIData(t *testing.T) {
 	w.Write("000000001110") // TOT section length
 	w.Write(totBytes())     // TOT data
 	w.Write(uint32(32))     // TOT CRC32
-	_, err := parsePSIData(astikit.NewBytesIterator(buf.Bytes()))
-	assert.EqualError(t, err, "astits: parsing PSI table failed: astits: Table CRC32 20 != computed CRC32 6969b13")
+	_, err := parsePSIData(astikit.NewBytesIterator(buf.Bytes()), false)
+	assert.EqualError(t, err, "astits: parsing PSI table failed: astits: invalid PSI CRC32: table CRC32 20 != computed CRC32 6969b13")
+
+	// Invalid CRC32 is tolerated
+	d, err := parsePSIData(astikit.NewBytesIterator(buf.Bytes()), true)
+	assert.NoError(t, err)
+	assert.Len(t, d.Sections, 1)
+	assert.True(t, d.Sections[0].Unverified)
+	assert.Equal(t, uint32(32), d.Sections[0].CRC32)
+	assert.NotNil(t, d.Sections[0].Syntax.Data.TOT)
 
 	// Valid
-	d, err := parsePSIData(astikit.NewBytesIterator(psiBytes()))
+	d, err = parsePSIData(astikit.NewBytesIterator(psiBytes()), false)
 	assert.NoError(t, err)
 	assert.Equal(t, d, psi)
 }
@@ -230,6 +238,7 @@ func TestPSITableType(t *testing.T) {
 	assert.Equal(t, PSITableTypeSDT, PSITableIDSDTVariant2.Type())
 
 	assert.Equal(t, PSITableTypeBAT, PSITableIDBAT.Type())
+	assert.Equal(t, PSITableTypeCAT, PSITableIDCAT.Type())
 	assert.Equal(t, PSITableTypeNull, PSITableIDNull.Type())
 	assert.Equal(t, PSITableTypePAT, PSITableIDPAT.Type())
 	assert.Equal(t, PSITableTypePMT, PSITableIDPMT.Type())
@@ -238,7 +247,7 @@ func TestPSITableType(t *testing.T) {
 	assert.Equal(t, PSITableTypeST, PSITableIDST.Type())
 	assert.Equal(t, PSITableTypeTDT, PSITableIDTDT.Type())
 	assert.Equal(t, PSITableTypeTOT, PSITableIDTOT.Type())
-	assert.Equal(t, PSITableTypeUnknown, PSITableID(1).Type())
+	assert.Equal(t, PSITableTypeUnknown, PSITableID(4).Type())
 }
 
 var psiSectionSyntaxHeader = &PSISectionSyntaxHeader{
@@ -354,6 +363,51 @@ var psiDataTestCases = []psiDataTestCase{
 			},
 		},
 	},
+	{
+		"EIT",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(0))                      // Pointer field
+			w.Write(uint8(78))                     // EIT table ID
+			w.Write("1")                           // EIT syntax section indicator
+			w.Write("1")                           // EIT private bit
+			w.Write("11")                          // EIT reserved
+			w.Write("000000011110")                // EIT section length
+			w.Write(psiSectionSyntaxHeaderBytes()) // EIT syntax section header
+			w.Write(eitBytes())                    // EIT data
+			w.Write(uint32(0x7ffc6102))            // EIT CRC32
+		},
+		&PSIData{
+			Sections: []*PSISection{
+				{
+					CRC32: uint32(0x7ffc6102),
+					Header: &PSISectionHeader{
+						PrivateBit:             true,
+						SectionLength:          30,
+						SectionSyntaxIndicator: true,
+						TableID:                78,
+						TableType:              PSITableTypeEIT,
+					},
+					Syntax: &PSISectionSyntax{
+						Data:   &PSISectionSyntaxData{EIT: eit},
+						Header: psiSectionSyntaxHeader,
+					},
+				},
+			},
+		},
+	},
+	{
+		"TDT",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(0))       // Pointer field
+			w.Write(uint8(112))     // TDT table ID
+			w.Write("0")            // TDT syntax section indicator
+			w.Write("1")            // TDT private bit
+			w.Write("11")           // TDT reserved
+			w.Write("000000000101") // TDT section length
+			w.Write(dvbTimeBytes)   // TDT UTC time
+		},
+		&PSIData{Sections: []*PSISection{newTDTSection(&TDTData{UTCTime: dvbTime})}},
+	},
 }
 
 func TestWritePSIData(t *testing.T) {
@@ -375,10 +429,51 @@ func TestWritePSIData(t *testing.T) {
 	}
 }
 
+func TestWritePSISectionRoundTrip(t *testing.T) {
+	for _, s := range []*PSISection{
+		newPSISection(PSITableIDCAT, 0xffff, 3, &PSISectionSyntaxData{CAT: cat}),
+		newPSISection(PSITableIDNITVariant1, nit.NetworkID, 1, &PSISectionSyntaxData{NIT: nit}),
+		newPSISection(PSITableIDSDTVariant1, sdt.TransportStreamID, 2, &PSISectionSyntaxData{SDT: sdt}),
+		newTOTSection(tot),
+	} {
+		t.Run(s.Header.TableType, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &buf})
+			n, err := writePSIData(w, &PSIData{Sections: []*PSISection{s}})
+			assert.NoError(t, err)
+			assert.Equal(t, buf.Len(), n)
+			assert.Equal(t, int(s.Header.SectionLength)+4, n) // Pointer field and section header
+
+			// Parsing checks the CRC32
+			d, err := parsePSIData(astikit.NewBytesIterator(buf.Bytes()), false)
+			assert.NoError(t, err)
+			assert.Len(t, d.Sections, 1)
+			assert.Equal(t, s.Header, d.Sections[0].Header)
+			assert.Equal(t, s.Syntax, d.Sections[0].Syntax)
+		})
+	}
+}
+
+func TestSplitPSISectionItems(t *testing.T) {
+	// No items
+	cs, err := splitPSISectionItems(nil, 10, 5, 1021)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0}, cs)
+
+	// Items fill the first section, which is larger, and the following sections
+	cs, err = splitPSISectionItems([]int{500, 400, 100, 500, 500, 12}, 100, 0, 1021)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 2, 2}, cs)
+
+	// Item is too large
+	_, err = splitPSISectionItems([]int{1013}, 0, 0, 1021)
+	assert.Equal(t, ErrPSISectionTooLarge, err)
+}
+
 func BenchmarkParsePSIData(b *testing.B) {
 	pb := psiBytes()
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		parsePSIData(astikit.NewBytesIterator(pb))
+		parsePSIData(astikit.NewBytesIterator(pb), false)
 	}
 }
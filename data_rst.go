@@ -0,0 +1,49 @@
+package astits
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astikit"
+)
+
+// RSTData represents a RST data
+// Page: 40 | Chapter: 5.2.7 | Link: https://www.dvb.org/resources/public/standards/a38_dvb-si_specification.pdf
+// (barbashov) the link above can be broken, alternative: https://dvb.org/wp-content/uploads/2019/12/a038_tm1217r37_en300468v1_17_1_-_rev-134_-_si_specification.pdf
+type RSTData struct {
+	Events []*RSTDataEvent
+}
+
+// RSTDataEvent represents the running status of an event
+type RSTDataEvent struct {
+	EventID           uint16
+	OriginalNetworkID uint16
+	RunningStatus     uint8
+	ServiceID         uint16
+	TransportStreamID uint16
+}
+
+// parseRSTSection parses a RST section
+func parseRSTSection(i *astikit.BytesIterator, offsetSectionsEnd int) (d *RSTData, err error) {
+	// Create data
+	d = &RSTData{}
+
+	// Loop until end of section data is reached
+	for i.Offset() < offsetSectionsEnd {
+		// Get next bytes
+		var bs []byte
+		if bs, err = i.NextBytesNoCopy(9); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+
+		// Append event
+		d.Events = append(d.Events, &RSTDataEvent{
+			EventID:           uint16(bs[6])<<8 | uint16(bs[7]),
+			OriginalNetworkID: uint16(bs[2])<<8 | uint16(bs[3]),
+			RunningStatus:     uint8(bs[8] & 0x7),
+			ServiceID:         uint16(bs[4])<<8 | uint16(bs[5]),
+			TransportStreamID: uint16(bs[0])<<8 | uint16(bs[1]),
+		})
+	}
+	return
+}
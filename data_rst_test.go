@@ -0,0 +1,36 @@
+package astits
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+var rst = &RSTData{Events: []*RSTDataEvent{{
+	EventID:           4,
+	OriginalNetworkID: 2,
+	RunningStatus:     RunningStatusRunning,
+	ServiceID:         3,
+	TransportStreamID: 1,
+}}}
+
+func rstBytes() []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write(uint16(1)) // Event #1 transport stream id
+	w.Write(uint16(2)) // Event #1 original network id
+	w.Write(uint16(3)) // Event #1 service id
+	w.Write(uint16(4)) // Event #1 event id
+	w.Write("11111")   // Event #1 reserved for future use
+	w.Write("100")     // Event #1 running status
+	return buf.Bytes()
+}
+
+func TestParseRSTSection(t *testing.T) {
+	var b = rstBytes()
+	d, err := parseRSTSection(astikit.NewBytesIterator(b), len(b))
+	assert.Equal(t, d, rst)
+	assert.NoError(t, err)
+}
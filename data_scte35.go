@@ -0,0 +1,815 @@
+package astits
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astikit"
+)
+
+// SCTE-35 splice command types
+// Chapter: 9.7 | Link: https://www.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+const (
+	SCTE35SpliceCommandTypeBandwidthReservation = 0x7
+	SCTE35SpliceCommandTypePrivateCommand       = 0xff
+	SCTE35SpliceCommandTypeSpliceInsert         = 0x5
+	SCTE35SpliceCommandTypeSpliceNull           = 0x0
+	SCTE35SpliceCommandTypeSpliceSchedule       = 0x4
+	SCTE35SpliceCommandTypeTimeSignal           = 0x6
+)
+
+// SCTE-35 splice descriptor tags
+// Chapter: 10.2 | Link: https://www.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+const (
+	SCTE35DescriptorTagAvail        = 0x0
+	SCTE35DescriptorTagDTMF         = 0x1
+	SCTE35DescriptorTagSegmentation = 0x2
+	SCTE35DescriptorTagTime         = 0x3
+)
+
+// scte35Identifier is the identifier of the splice descriptors defined by SCTE-35, "CUEI"
+const scte35Identifier = 0x43554549
+
+// isSCTE35ElementaryStream checks whether an elementary stream carries SCTE-35 splice info sections: either its
+// stream type is the SCTE-35 one, or it's private data registered with the "CUEI" format identifier
+func isSCTE35ElementaryStream(es *PMTElementaryStream) bool {
+	if es.StreamType == StreamTypeSCTE35 {
+		return true
+	} else if es.StreamType != StreamTypePrivateData {
+		return false
+	}
+	for _, d := range es.ElementaryStreamDescriptors {
+		if d.Registration != nil && d.Registration.FormatIdentifier == scte35Identifier {
+			return true
+		}
+	}
+	return false
+}
+
+// SCTE35Data represents a SCTE-35 splice info section
+// Only the field matching the splice command type is set. The splice command and the descriptors of encrypted
+// sections are not parsed.
+// Chapter: 9.6 | Link: https://www.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+type SCTE35Data struct {
+	BandwidthReservation *SCTE35BandwidthReservation
+	CWIndex              uint8
+	Descriptors          []*SCTE35Descriptor
+	EncryptedData        []byte // Splice command and descriptors of encrypted sections
+	EncryptedPacket      bool
+	EncryptionAlgorithm  uint8
+	PrivateCommand       *SCTE35PrivateCommand
+	ProtocolVersion      uint8
+	PTSAdjustment        *ClockReference // Added to the splice times of the section
+	SAPType              uint8
+	SpliceCommandType    uint8
+	SpliceInsert         *SCTE35SpliceInsert
+	SpliceSchedule       *SCTE35SpliceSchedule
+	Tier                 uint16
+	TimeSignal           *SCTE35TimeSignal
+}
+
+// SCTE35BreakDuration represents a SCTE-35 break duration
+type SCTE35BreakDuration struct {
+	AutoReturn bool
+	Duration   *ClockReference
+}
+
+// SCTE35SpliceInsert represents a SCTE-35 splice insert command
+type SCTE35SpliceInsert struct {
+	AvailNum                   uint8
+	AvailsExpected             uint8
+	BreakDuration              *SCTE35BreakDuration // nil if the splice has no duration
+	Components                 []*SCTE35SpliceInsertComponent
+	EventIDComplianceFlag      bool
+	OutOfNetworkIndicator      bool
+	ProgramSpliceFlag          bool
+	SpliceEventCancelIndicator bool
+	SpliceEventID              uint32
+	SpliceImmediateFlag        bool
+	SpliceTime                 *ClockReference // Only set for program splices that are not immediate and whose time is specified
+	UniqueProgramID            uint16
+}
+
+// SCTE35SpliceInsertComponent represents a component of a SCTE-35 splice insert command
+type SCTE35SpliceInsertComponent struct {
+	ComponentTag uint8
+	SpliceTime   *ClockReference // Only set for splices that are not immediate and whose time is specified
+}
+
+// SCTE35SpliceSchedule represents a SCTE-35 splice schedule command
+type SCTE35SpliceSchedule struct {
+	Events []*SCTE35SpliceScheduleEvent
+}
+
+// SCTE35SpliceScheduleEvent represents an event of a SCTE-35 splice schedule command
+type SCTE35SpliceScheduleEvent struct {
+	AvailNum                   uint8
+	AvailsExpected             uint8
+	BreakDuration              *SCTE35BreakDuration // nil if the splice has no duration
+	Components                 []*SCTE35SpliceScheduleComponent
+	OutOfNetworkIndicator      bool
+	ProgramSpliceFlag          bool
+	SpliceEventCancelIndicator bool
+	SpliceEventID              uint32
+	UniqueProgramID            uint16
+	UTCSpliceTime              uint32 // Number of seconds since 1980-01-06 00:00:00 UTC, including leap seconds. Only set for program splices.
+}
+
+// SCTE35SpliceScheduleComponent represents a component of an event of a SCTE-35 splice schedule command
+type SCTE35SpliceScheduleComponent struct {
+	ComponentTag  uint8
+	UTCSpliceTime uint32 // Number of seconds since 1980-01-06 00:00:00 UTC, including leap seconds
+}
+
+// SCTE35TimeSignal represents a SCTE-35 time signal command
+type SCTE35TimeSignal struct {
+	SpliceTime *ClockReference // nil if the time is not specified
+}
+
+// SCTE35BandwidthReservation represents a SCTE-35 bandwidth reservation command, which has no data
+type SCTE35BandwidthReservation struct{}
+
+// SCTE35PrivateCommand represents a SCTE-35 private command
+type SCTE35PrivateCommand struct {
+	Identifier   uint32
+	PrivateBytes []byte
+}
+
+// SCTE35Descriptor represents a SCTE-35 splice descriptor
+// Only descriptors whose identifier is "CUEI" are parsed, the data of the other ones is stored in Unknown
+type SCTE35Descriptor struct {
+	Avail        *SCTE35DescriptorAvail
+	DTMF         *SCTE35DescriptorDTMF
+	Identifier   uint32
+	Length       uint8
+	Segmentation *SCTE35DescriptorSegmentation
+	Tag          uint8
+	Time         *SCTE35DescriptorTime
+	Unknown      []byte // Data following the identifier
+}
+
+// SCTE35DescriptorAvail represents a SCTE-35 avail descriptor
+// Chapter: 10.3.1 | Link: https://www.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+type SCTE35DescriptorAvail struct {
+	ProviderAvailID uint32
+}
+
+// SCTE35DescriptorDTMF represents a SCTE-35 DTMF descriptor
+// Chapter: 10.3.2 | Link: https://www.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+type SCTE35DescriptorDTMF struct {
+	Chars   []byte
+	Preroll uint8 // In tenths of second
+}
+
+// SCTE35DescriptorSegmentation represents a SCTE-35 segmentation descriptor
+// Chapter: 10.3.3 | Link: https://www.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+type SCTE35DescriptorSegmentation struct {
+	ArchiveAllowedFlag                     bool
+	Components                             []*SCTE35DescriptorSegmentationComponent
+	DeliveryNotRestrictedFlag              bool
+	DeviceRestrictions                     uint8
+	HasSubSegments                         bool
+	NoRegionalBlackoutFlag                 bool
+	ProgramSegmentationFlag                bool
+	SegmentationDuration                   *ClockReference // nil if the segment has no duration
+	SegmentationEventCancelIndicator       bool
+	SegmentationEventID                    uint32
+	SegmentationEventIDComplianceIndicator bool
+	SegmentationTypeID                     uint8
+	SegmentationUPID                       []byte
+	SegmentationUPIDType                   uint8
+	SegmentNum                             uint8
+	SegmentsExpected                       uint8
+	SubSegmentNum                          uint8
+	SubSegmentsExpected                    uint8
+	WebDeliveryAllowedFlag                 bool
+}
+
+// SCTE35DescriptorSegmentationComponent represents a component of a SCTE-35 segmentation descriptor
+type SCTE35DescriptorSegmentationComponent struct {
+	ComponentTag uint8
+	PTSOffset    *ClockReference
+}
+
+// SCTE35DescriptorTime represents a SCTE-35 time descriptor
+// Chapter: 10.3.4 | Link: https://www.scte.org/standards/library/catalog/scte-35-digital-program-insertion-cueing-message/
+type SCTE35DescriptorTime struct {
+	TAINanoseconds uint32
+	TAISeconds     uint64
+	UTCOffset      uint16
+}
+
+// parseSCTE35Section parses a SCTE-35 splice info section
+func parseSCTE35Section(i *astikit.BytesIterator, offsetSectionsEnd int) (d *SCTE35Data, err error) {
+	// Create data
+	d = &SCTE35Data{}
+
+	// We need to rewind since the SAP type is part of the section header
+	i.Skip(-2)
+
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// SAP type
+	d.SAPType = uint8(b>>4) & 0x3
+
+	// Move back to the end of the section header
+	i.Skip(1)
+
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(10); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Protocol version
+	d.ProtocolVersion = uint8(bs[0])
+
+	// Encryption
+	d.EncryptedPacket = bs[1]&0x80 > 0
+	d.EncryptionAlgorithm = uint8(bs[1]>>1) & 0x3f
+
+	// PTS adjustment
+	d.PTSAdjustment = newClockReference(int64(uint64(bs[1]&0x1)<<32|uint64(bs[2])<<24|uint64(bs[3])<<16|uint64(bs[4])<<8|uint64(bs[5])), 0)
+
+	// CW index
+	d.CWIndex = uint8(bs[6])
+
+	// Tier
+	d.Tier = uint16(bs[7])<<4 | uint16(bs[8])>>4
+
+	// Splice command length
+	spliceCommandLength := int(uint16(bs[8]&0xf)<<8 | uint16(bs[9]))
+
+	// Encrypted data, which starts with the splice command type
+	if d.EncryptedPacket {
+		if d.EncryptedData, err = i.NextBytes(offsetSectionsEnd - i.Offset()); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+		return
+	}
+
+	// Get next byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Splice command type
+	d.SpliceCommandType = uint8(b)
+
+	// Splice command
+	offsetCommandStart := i.Offset()
+	switch d.SpliceCommandType {
+	case SCTE35SpliceCommandTypeBandwidthReservation:
+		d.BandwidthReservation = &SCTE35BandwidthReservation{}
+	case SCTE35SpliceCommandTypePrivateCommand:
+		if d.PrivateCommand, err = parseSCTE35PrivateCommand(i, spliceCommandLength); err != nil {
+			err = fmt.Errorf("astits: parsing private command failed: %w", err)
+			return
+		}
+	case SCTE35SpliceCommandTypeSpliceInsert:
+		if d.SpliceInsert, err = parseSCTE35SpliceInsert(i); err != nil {
+			err = fmt.Errorf("astits: parsing splice insert failed: %w", err)
+			return
+		}
+	case SCTE35SpliceCommandTypeSpliceSchedule:
+		if d.SpliceSchedule, err = parseSCTE35SpliceSchedule(i); err != nil {
+			err = fmt.Errorf("astits: parsing splice schedule failed: %w", err)
+			return
+		}
+	case SCTE35SpliceCommandTypeTimeSignal:
+		d.TimeSignal = &SCTE35TimeSignal{}
+		if d.TimeSignal.SpliceTime, err = parseSCTE35SpliceTime(i); err != nil {
+			err = fmt.Errorf("astits: parsing splice time failed: %w", err)
+			return
+		}
+	}
+
+	// Seek to the end of the splice command, unless its length is 0xfff which legacy encoders use when they don't
+	// know it
+	if spliceCommandLength != 0xfff {
+		i.Seek(offsetCommandStart + spliceCommandLength)
+	}
+
+	// Get next bytes
+	if bs, err = i.NextBytesNoCopy(2); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Descriptors
+	offsetEnd := i.Offset() + int(uint16(bs[0])<<8|uint16(bs[1]))
+	for i.Offset() < offsetEnd {
+		var sd *SCTE35Descriptor
+		if sd, err = parseSCTE35Descriptor(i); err != nil {
+			err = fmt.Errorf("astits: parsing descriptor failed: %w", err)
+			return
+		}
+		d.Descriptors = append(d.Descriptors, sd)
+	}
+	return
+}
+
+// parseSCTE35SpliceTime parses a SCTE-35 splice time and returns nil if the time is not specified
+func parseSCTE35SpliceTime(i *astikit.BytesIterator) (cr *ClockReference, err error) {
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Time is not specified
+	if b&0x80 == 0 {
+		return
+	}
+
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(4); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// PTS time
+	cr = newClockReference(int64(uint64(b&0x1)<<32|uint64(bs[0])<<24|uint64(bs[1])<<16|uint64(bs[2])<<8|uint64(bs[3])), 0)
+	return
+}
+
+// parseSCTE35BreakDuration parses a SCTE-35 break duration
+func parseSCTE35BreakDuration(i *astikit.BytesIterator) (d *SCTE35BreakDuration, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(5); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create break duration
+	d = &SCTE35BreakDuration{
+		AutoReturn: bs[0]&0x80 > 0,
+		Duration:   newClockReference(int64(uint64(bs[0]&0x1)<<32|uint64(bs[1])<<24|uint64(bs[2])<<16|uint64(bs[3])<<8|uint64(bs[4])), 0),
+	}
+	return
+}
+
+// parseSCTE35SpliceInsert parses a SCTE-35 splice insert command
+func parseSCTE35SpliceInsert(i *astikit.BytesIterator) (d *SCTE35SpliceInsert, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(5); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create splice insert
+	d = &SCTE35SpliceInsert{
+		SpliceEventCancelIndicator: bs[4]&0x80 > 0,
+		SpliceEventID:              uint32(bs[0])<<24 | uint32(bs[1])<<16 | uint32(bs[2])<<8 | uint32(bs[3]),
+	}
+
+	// Splice event is cancelled
+	if d.SpliceEventCancelIndicator {
+		return
+	}
+
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Flags
+	d.OutOfNetworkIndicator = b&0x80 > 0
+	d.ProgramSpliceFlag = b&0x40 > 0
+	durationFlag := b&0x20 > 0
+	d.SpliceImmediateFlag = b&0x10 > 0
+	d.EventIDComplianceFlag = b&0x8 > 0
+
+	// Program splice time
+	if d.ProgramSpliceFlag && !d.SpliceImmediateFlag {
+		if d.SpliceTime, err = parseSCTE35SpliceTime(i); err != nil {
+			err = fmt.Errorf("astits: parsing splice time failed: %w", err)
+			return
+		}
+	}
+
+	// Components
+	if !d.ProgramSpliceFlag {
+		// Get next byte
+		if b, err = i.NextByte(); err != nil {
+			err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+			return
+		}
+
+		// Loop through components
+		for idx := 0; idx < int(b); idx++ {
+			// Get next byte
+			var tag byte
+			if tag, err = i.NextByte(); err != nil {
+				err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+				return
+			}
+
+			// Create component
+			c := &SCTE35SpliceInsertComponent{ComponentTag: uint8(tag)}
+
+			// Splice time
+			if !d.SpliceImmediateFlag {
+				if c.SpliceTime, err = parseSCTE35SpliceTime(i); err != nil {
+					err = fmt.Errorf("astits: parsing splice time failed: %w", err)
+					return
+				}
+			}
+
+			// Append component
+			d.Components = append(d.Components, c)
+		}
+	}
+
+	// Break duration
+	if durationFlag {
+		if d.BreakDuration, err = parseSCTE35BreakDuration(i); err != nil {
+			err = fmt.Errorf("astits: parsing break duration failed: %w", err)
+			return
+		}
+	}
+
+	// Get next bytes
+	if bs, err = i.NextBytesNoCopy(4); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Avails
+	d.UniqueProgramID = uint16(bs[0])<<8 | uint16(bs[1])
+	d.AvailNum = uint8(bs[2])
+	d.AvailsExpected = uint8(bs[3])
+	return
+}
+
+// parseSCTE35SpliceSchedule parses a SCTE-35 splice schedule command
+func parseSCTE35SpliceSchedule(i *astikit.BytesIterator) (d *SCTE35SpliceSchedule, err error) {
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Create splice schedule
+	d = &SCTE35SpliceSchedule{}
+
+	// Loop through events
+	for idx := 0; idx < int(b); idx++ {
+		var e *SCTE35SpliceScheduleEvent
+		if e, err = parseSCTE35SpliceScheduleEvent(i); err != nil {
+			err = fmt.Errorf("astits: parsing event failed: %w", err)
+			return
+		}
+		d.Events = append(d.Events, e)
+	}
+	return
+}
+
+// parseSCTE35SpliceScheduleEvent parses an event of a SCTE-35 splice schedule command
+func parseSCTE35SpliceScheduleEvent(i *astikit.BytesIterator) (e *SCTE35SpliceScheduleEvent, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(5); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create event
+	e = &SCTE35SpliceScheduleEvent{
+		SpliceEventCancelIndicator: bs[4]&0x80 > 0,
+		SpliceEventID:              uint32(bs[0])<<24 | uint32(bs[1])<<16 | uint32(bs[2])<<8 | uint32(bs[3]),
+	}
+
+	// Splice event is cancelled
+	if e.SpliceEventCancelIndicator {
+		return
+	}
+
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Flags
+	e.OutOfNetworkIndicator = b&0x80 > 0
+	e.ProgramSpliceFlag = b&0x40 > 0
+	durationFlag := b&0x20 > 0
+
+	if e.ProgramSpliceFlag {
+		// Get next bytes
+		if bs, err = i.NextBytesNoCopy(4); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+
+		// UTC splice time
+		e.UTCSpliceTime = uint32(bs[0])<<24 | uint32(bs[1])<<16 | uint32(bs[2])<<8 | uint32(bs[3])
+	} else {
+		// Get next byte
+		if b, err = i.NextByte(); err != nil {
+			err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+			return
+		}
+
+		// Loop through components
+		for idx := 0; idx < int(b); idx++ {
+			// Get next bytes
+			if bs, err = i.NextBytesNoCopy(5); err != nil {
+				err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+				return
+			}
+
+			// Append component
+			e.Components = append(e.Components, &SCTE35SpliceScheduleComponent{
+				ComponentTag:  uint8(bs[0]),
+				UTCSpliceTime: uint32(bs[1])<<24 | uint32(bs[2])<<16 | uint32(bs[3])<<8 | uint32(bs[4]),
+			})
+		}
+	}
+
+	// Break duration
+	if durationFlag {
+		if e.BreakDuration, err = parseSCTE35BreakDuration(i); err != nil {
+			err = fmt.Errorf("astits: parsing break duration failed: %w", err)
+			return
+		}
+	}
+
+	// Get next bytes
+	if bs, err = i.NextBytesNoCopy(4); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Avails
+	e.UniqueProgramID = uint16(bs[0])<<8 | uint16(bs[1])
+	e.AvailNum = uint8(bs[2])
+	e.AvailsExpected = uint8(bs[3])
+	return
+}
+
+// parseSCTE35PrivateCommand parses a SCTE-35 private command
+func parseSCTE35PrivateCommand(i *astikit.BytesIterator, spliceCommandLength int) (d *SCTE35PrivateCommand, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(4); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create private command
+	d = &SCTE35PrivateCommand{Identifier: uint32(bs[0])<<24 | uint32(bs[1])<<16 | uint32(bs[2])<<8 | uint32(bs[3])}
+
+	// Private bytes, whose length is unknown when the splice command length is unknown
+	if spliceCommandLength != 0xfff && spliceCommandLength > 4 {
+		if d.PrivateBytes, err = i.NextBytes(spliceCommandLength - 4); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+	}
+	return
+}
+
+// parseSCTE35Descriptor parses a SCTE-35 splice descriptor
+func parseSCTE35Descriptor(i *astikit.BytesIterator) (d *SCTE35Descriptor, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(2); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &SCTE35Descriptor{
+		Length: uint8(bs[1]),
+		Tag:    uint8(bs[0]),
+	}
+
+	// Unfortunately there's no way to be sure the real descriptor length is the same as the one indicated
+	// previously therefore we must fetch bytes in descriptor functions and seek at the end
+	offsetEnd := i.Offset() + int(d.Length)
+	defer i.Seek(offsetEnd)
+
+	// Get next bytes
+	if bs, err = i.NextBytesNoCopy(4); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Identifier
+	d.Identifier = uint32(bs[0])<<24 | uint32(bs[1])<<16 | uint32(bs[2])<<8 | uint32(bs[3])
+
+	// Switch on tag
+	if d.Identifier == scte35Identifier {
+		switch d.Tag {
+		case SCTE35DescriptorTagAvail:
+			if d.Avail, err = newSCTE35DescriptorAvail(i); err != nil {
+				err = fmt.Errorf("astits: parsing avail descriptor failed: %w", err)
+			}
+			return
+		case SCTE35DescriptorTagDTMF:
+			if d.DTMF, err = newSCTE35DescriptorDTMF(i); err != nil {
+				err = fmt.Errorf("astits: parsing DTMF descriptor failed: %w", err)
+			}
+			return
+		case SCTE35DescriptorTagSegmentation:
+			if d.Segmentation, err = newSCTE35DescriptorSegmentation(i, offsetEnd); err != nil {
+				err = fmt.Errorf("astits: parsing segmentation descriptor failed: %w", err)
+			}
+			return
+		case SCTE35DescriptorTagTime:
+			if d.Time, err = newSCTE35DescriptorTime(i); err != nil {
+				err = fmt.Errorf("astits: parsing time descriptor failed: %w", err)
+			}
+			return
+		}
+	}
+
+	// Unknown
+	if i.Offset() < offsetEnd {
+		if d.Unknown, err = i.NextBytes(offsetEnd - i.Offset()); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+	}
+	return
+}
+
+func newSCTE35DescriptorAvail(i *astikit.BytesIterator) (d *SCTE35DescriptorAvail, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(4); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &SCTE35DescriptorAvail{ProviderAvailID: uint32(bs[0])<<24 | uint32(bs[1])<<16 | uint32(bs[2])<<8 | uint32(bs[3])}
+	return
+}
+
+func newSCTE35DescriptorDTMF(i *astikit.BytesIterator) (d *SCTE35DescriptorDTMF, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(2); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &SCTE35DescriptorDTMF{Preroll: uint8(bs[0])}
+
+	// Chars
+	if d.Chars, err = i.NextBytes(int(bs[1] >> 5)); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+	return
+}
+
+func newSCTE35DescriptorSegmentation(i *astikit.BytesIterator, offsetEnd int) (d *SCTE35DescriptorSegmentation, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(5); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &SCTE35DescriptorSegmentation{
+		SegmentationEventCancelIndicator:       bs[4]&0x80 > 0,
+		SegmentationEventID:                    uint32(bs[0])<<24 | uint32(bs[1])<<16 | uint32(bs[2])<<8 | uint32(bs[3]),
+		SegmentationEventIDComplianceIndicator: bs[4]&0x40 > 0,
+	}
+
+	// Segmentation event is cancelled
+	if d.SegmentationEventCancelIndicator {
+		return
+	}
+
+	// Get next byte
+	var b byte
+	if b, err = i.NextByte(); err != nil {
+		err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+		return
+	}
+
+	// Flags
+	d.ProgramSegmentationFlag = b&0x80 > 0
+	durationFlag := b&0x40 > 0
+	d.DeliveryNotRestrictedFlag = b&0x20 > 0
+	if !d.DeliveryNotRestrictedFlag {
+		d.WebDeliveryAllowedFlag = b&0x10 > 0
+		d.NoRegionalBlackoutFlag = b&0x8 > 0
+		d.ArchiveAllowedFlag = b&0x4 > 0
+		d.DeviceRestrictions = uint8(b & 0x3)
+	}
+
+	// Components
+	if !d.ProgramSegmentationFlag {
+		// Get next byte
+		if b, err = i.NextByte(); err != nil {
+			err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+			return
+		}
+
+		// Loop through components
+		for idx := 0; idx < int(b); idx++ {
+			// Get next bytes
+			if bs, err = i.NextBytesNoCopy(6); err != nil {
+				err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+				return
+			}
+
+			// Append component
+			d.Components = append(d.Components, &SCTE35DescriptorSegmentationComponent{
+				ComponentTag: uint8(bs[0]),
+				PTSOffset:    newClockReference(int64(uint64(bs[1]&0x1)<<32|uint64(bs[2])<<24|uint64(bs[3])<<16|uint64(bs[4])<<8|uint64(bs[5])), 0),
+			})
+		}
+	}
+
+	// Segmentation duration
+	if durationFlag {
+		// Get next bytes
+		if bs, err = i.NextBytesNoCopy(5); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+		d.SegmentationDuration = newClockReference(int64(uint64(bs[0])<<32|uint64(bs[1])<<24|uint64(bs[2])<<16|uint64(bs[3])<<8|uint64(bs[4])), 0)
+	}
+
+	// Get next bytes
+	if bs, err = i.NextBytesNoCopy(2); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Segmentation UPID
+	d.SegmentationUPIDType = uint8(bs[0])
+	if d.SegmentationUPID, err = i.NextBytes(int(bs[1])); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Get next bytes
+	if bs, err = i.NextBytesNoCopy(3); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Segmentation type
+	d.SegmentationTypeID = uint8(bs[0])
+	d.SegmentNum = uint8(bs[1])
+	d.SegmentsExpected = uint8(bs[2])
+
+	// Sub segments are only present with some segmentation types and may be missing in streams following older
+	// versions of the standard
+	switch d.SegmentationTypeID {
+	case 0x34, 0x36, 0x38, 0x3a:
+		if i.Offset()+2 <= offsetEnd {
+			// Get next bytes
+			if bs, err = i.NextBytesNoCopy(2); err != nil {
+				err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+				return
+			}
+			d.HasSubSegments = true
+			d.SubSegmentNum = uint8(bs[0])
+			d.SubSegmentsExpected = uint8(bs[1])
+		}
+	}
+	return
+}
+
+func newSCTE35DescriptorTime(i *astikit.BytesIterator) (d *SCTE35DescriptorTime, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(12); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &SCTE35DescriptorTime{
+		TAINanoseconds: uint32(bs[6])<<24 | uint32(bs[7])<<16 | uint32(bs[8])<<8 | uint32(bs[9]),
+		TAISeconds:     uint64(bs[0])<<40 | uint64(bs[1])<<32 | uint64(bs[2])<<24 | uint64(bs[3])<<16 | uint64(bs[4])<<8 | uint64(bs[5]),
+		UTCOffset:      uint16(bs[10])<<8 | uint16(bs[11]),
+	}
+	return
+}
@@ -0,0 +1,275 @@
+package astits
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+var scte35SpliceInsert = &SCTE35SpliceInsert{
+	AvailNum:       2,
+	AvailsExpected: 3,
+	BreakDuration: &SCTE35BreakDuration{
+		AutoReturn: true,
+		Duration:   newClockReference(2700000, 0),
+	},
+	EventIDComplianceFlag: true,
+	OutOfNetworkIndicator: true,
+	ProgramSpliceFlag:     true,
+	SpliceEventID:         0x4800008f,
+	SpliceTime:            newClockReference(0x100000005, 0),
+	UniqueProgramID:       1,
+}
+
+func scte35SpliceInsertBytes() []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write(uint32(0x4800008f))       // Splice event id
+	w.Write("0")                      // Splice event cancel indicator
+	w.Write("1111111")                // Reserved
+	w.Write("1")                      // Out of network indicator
+	w.Write("1")                      // Program splice flag
+	w.Write("1")                      // Duration flag
+	w.Write("0")                      // Splice immediate flag
+	w.Write("1")                      // Event id compliance flag
+	w.Write("111")                    // Reserved
+	w.Write("1")                      // Splice time specified flag
+	w.Write("111111")                 // Splice time reserved
+	w.WriteN(uint64(0x100000005), 33) // Splice time PTS
+	w.Write("1")                      // Break duration auto return
+	w.Write("111111")                 // Break duration reserved
+	w.WriteN(uint64(2700000), 33)     // Break duration
+	w.Write(uint16(1))                // Unique program id
+	w.Write(uint8(2))                 // Avail num
+	w.Write(uint8(3))                 // Avails expected
+	return buf.Bytes()
+}
+
+var scte35Descriptors = []*SCTE35Descriptor{
+	{
+		Avail:      &SCTE35DescriptorAvail{ProviderAvailID: 0x1234},
+		Identifier: scte35Identifier,
+		Length:     8,
+		Tag:        SCTE35DescriptorTagAvail,
+	},
+	{
+		DTMF:       &SCTE35DescriptorDTMF{Chars: []byte("12"), Preroll: 5},
+		Identifier: scte35Identifier,
+		Length:     8,
+		Tag:        SCTE35DescriptorTagDTMF,
+	},
+	{
+		Identifier: scte35Identifier,
+		Length:     24,
+		Segmentation: &SCTE35DescriptorSegmentation{
+			ArchiveAllowedFlag:                     true,
+			DeviceRestrictions:                     2,
+			HasSubSegments:                         true,
+			ProgramSegmentationFlag:                true,
+			SegmentationDuration:                   newClockReference(900000, 0),
+			SegmentationEventID:                    0x12345678,
+			SegmentationEventIDComplianceIndicator: true,
+			SegmentationTypeID:                     0x34,
+			SegmentationUPID:                       []byte("ab"),
+			SegmentationUPIDType:                   0x9,
+			SegmentNum:                             1,
+			SegmentsExpected:                       2,
+			SubSegmentNum:                          3,
+			SubSegmentsExpected:                    4,
+			WebDeliveryAllowedFlag:                 true,
+		},
+		Tag: SCTE35DescriptorTagSegmentation,
+	},
+	{
+		Identifier: scte35Identifier,
+		Length:     16,
+		Tag:        SCTE35DescriptorTagTime,
+		Time: &SCTE35DescriptorTime{
+			TAINanoseconds: 500,
+			TAISeconds:     0x123456789a,
+			UTCOffset:      37,
+		},
+	},
+	{
+		Identifier: 0x41424344,
+		Length:     6,
+		Tag:        0xf0,
+		Unknown:    []byte("xy"),
+	},
+}
+
+func scte35DescriptorsBytes() []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write(uint8(SCTE35DescriptorTagAvail))        // Avail tag
+	w.Write(uint8(8))                               // Avail length
+	w.Write([]byte("CUEI"))                         // Avail identifier
+	w.Write(uint32(0x1234))                         // Avail provider avail id
+	w.Write(uint8(SCTE35DescriptorTagDTMF))         // DTMF tag
+	w.Write(uint8(8))                               // DTMF length
+	w.Write([]byte("CUEI"))                         // DTMF identifier
+	w.Write(uint8(5))                               // DTMF preroll
+	w.Write("010")                                  // DTMF count
+	w.Write("11111")                                // DTMF reserved
+	w.Write([]byte("12"))                           // DTMF chars
+	w.Write(uint8(SCTE35DescriptorTagSegmentation)) // Segmentation tag
+	w.Write(uint8(24))                              // Segmentation length
+	w.Write([]byte("CUEI"))                         // Segmentation identifier
+	w.Write(uint32(0x12345678))                     // Segmentation event id
+	w.Write("0")                                    // Segmentation event cancel indicator
+	w.Write("1")                                    // Segmentation event id compliance indicator
+	w.Write("111111")                               // Segmentation reserved
+	w.Write("1")                                    // Segmentation program segmentation flag
+	w.Write("1")                                    // Segmentation duration flag
+	w.Write("0")                                    // Segmentation delivery not restricted flag
+	w.Write("1")                                    // Segmentation web delivery allowed flag
+	w.Write("0")                                    // Segmentation no regional blackout flag
+	w.Write("1")                                    // Segmentation archive allowed flag
+	w.Write("10")                                   // Segmentation device restrictions
+	w.WriteN(uint64(900000), 40)                    // Segmentation duration
+	w.Write(uint8(0x9))                             // Segmentation UPID type
+	w.Write(uint8(2))                               // Segmentation UPID length
+	w.Write([]byte("ab"))                           // Segmentation UPID
+	w.Write(uint8(0x34))                            // Segmentation type id
+	w.Write(uint8(1))                               // Segmentation segment num
+	w.Write(uint8(2))                               // Segmentation segments expected
+	w.Write(uint8(3))                               // Segmentation sub segment num
+	w.Write(uint8(4))                               // Segmentation sub segments expected
+	w.Write(uint8(SCTE35DescriptorTagTime))         // Time tag
+	w.Write(uint8(16))                              // Time length
+	w.Write([]byte("CUEI"))                         // Time identifier
+	w.WriteN(uint64(0x123456789a), 48)              // Time TAI seconds
+	w.Write(uint32(500))                            // Time TAI nanoseconds
+	w.Write(uint16(37))                             // Time UTC offset
+	w.Write(uint8(0xf0))                            // Unknown tag
+	w.Write(uint8(6))                               // Unknown length
+	w.Write(uint32(0x41424344))                     // Unknown identifier
+	w.Write([]byte("xy"))                           // Unknown data
+	return buf.Bytes()
+}
+
+// scte35Bytes returns the splice info section data following the section length
+func scte35Bytes(spliceCommandType uint8, spliceCommandBytes, descriptorsBytes []byte) []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write(uint8(0))                             // Protocol version
+	w.Write("0")                                  // Encrypted packet
+	w.Write("000000")                             // Encryption algorithm
+	w.WriteN(uint64(10), 33)                      // PTS adjustment
+	w.Write(uint8(0xff))                          // CW index
+	w.WriteN(uint16(0xfff), 12)                   // Tier
+	w.WriteN(uint16(len(spliceCommandBytes)), 12) // Splice command length
+	w.Write(spliceCommandType)                    // Splice command type
+	w.Write(spliceCommandBytes)                   // Splice command
+	w.Write(uint16(len(descriptorsBytes)))        // Descriptor loop length
+	w.Write(descriptorsBytes)                     // Descriptors
+	return buf.Bytes()
+}
+
+// scte35PSIBytes returns a PSI payload with a splice info section
+func scte35PSIBytes(b []byte) []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write(uint8(PSITableIDSCTE35))   // SCTE-35 table ID
+	w.Write("0")                       // SCTE-35 section syntax indicator
+	w.Write("0")                       // SCTE-35 private indicator
+	w.Write("01")                      // SCTE-35 SAP type
+	w.WriteN(uint16(len(b)+4), 12)     // SCTE-35 section length
+	w.Write(b)                         // SCTE-35 data
+	w.Write(computeCRC32(buf.Bytes())) // SCTE-35 CRC32
+	return append([]byte{0}, buf.Bytes()...)
+}
+
+func TestParseSCTE35Section(t *testing.T) {
+	for _, v := range []struct {
+		b    []byte
+		d    *SCTE35Data
+		name string
+	}{
+		{
+			b: scte35Bytes(SCTE35SpliceCommandTypeSpliceInsert, scte35SpliceInsertBytes(), scte35DescriptorsBytes()),
+			d: &SCTE35Data{
+				Descriptors:  scte35Descriptors,
+				SpliceInsert: scte35SpliceInsert,
+			},
+			name: "splice insert",
+		},
+		{
+			b:    scte35Bytes(SCTE35SpliceCommandTypeSpliceInsert, []byte{0x0, 0x0, 0x0, 0x1, 0xff}, nil),
+			d:    &SCTE35Data{SpliceInsert: &SCTE35SpliceInsert{SpliceEventCancelIndicator: true, SpliceEventID: 1}},
+			name: "cancelled splice insert",
+		},
+		{
+			b: scte35Bytes(SCTE35SpliceCommandTypeSpliceSchedule, []byte{
+				0x1,                // Splice count
+				0x0, 0x0, 0x0, 0x2, // Splice event id
+				0x7f,                    // Splice event cancel indicator
+				0x9f,                    // Out of network indicator, program splice flag and duration flag
+				0x1,                     // Component count
+				0x3, 0x0, 0x0, 0x1, 0x0, // Component tag and UTC splice time
+				0x0, 0x1, 0x4, 0x5, // Unique program id, avail num and avails expected
+			}, nil),
+			d: &SCTE35Data{SpliceSchedule: &SCTE35SpliceSchedule{Events: []*SCTE35SpliceScheduleEvent{{
+				AvailNum:              4,
+				AvailsExpected:        5,
+				Components:            []*SCTE35SpliceScheduleComponent{{ComponentTag: 3, UTCSpliceTime: 0x100}},
+				OutOfNetworkIndicator: true,
+				SpliceEventID:         2,
+				UniqueProgramID:       1,
+			}}}},
+			name: "splice schedule",
+		},
+		{
+			b:    scte35Bytes(SCTE35SpliceCommandTypeTimeSignal, []byte{0xff, 0x0, 0x0, 0x1, 0x0}, scte35DescriptorsBytes()[:10]),
+			d:    &SCTE35Data{Descriptors: scte35Descriptors[:1], TimeSignal: &SCTE35TimeSignal{SpliceTime: newClockReference(0x100000100, 0)}},
+			name: "time signal",
+		},
+		{
+			b:    scte35Bytes(SCTE35SpliceCommandTypeTimeSignal, []byte{0x7f}, nil),
+			d:    &SCTE35Data{TimeSignal: &SCTE35TimeSignal{}},
+			name: "time signal without time",
+		},
+		{
+			b:    scte35Bytes(SCTE35SpliceCommandTypeBandwidthReservation, nil, nil),
+			d:    &SCTE35Data{BandwidthReservation: &SCTE35BandwidthReservation{}},
+			name: "bandwidth reservation",
+		},
+		{
+			b:    scte35Bytes(SCTE35SpliceCommandTypePrivateCommand, []byte("ABCDtest"), nil),
+			d:    &SCTE35Data{PrivateCommand: &SCTE35PrivateCommand{Identifier: 0x41424344, PrivateBytes: []byte("test")}},
+			name: "private command",
+		},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			v.d.CWIndex = 0xff
+			v.d.PTSAdjustment = newClockReference(10, 0)
+			v.d.SAPType = 1
+			v.d.SpliceCommandType = v.b[10]
+			v.d.Tier = 0xfff
+
+			d, err := parsePSIData(astikit.NewBytesIterator(scte35PSIBytes(v.b)), false)
+			assert.NoError(t, err)
+			p := &Packet{}
+			assert.Equal(t, []*DemuxerData{{FirstPacket: p, PID: 0x1f0, SCTE35: v.d}}, d.toData(p, 0x1f0))
+		})
+	}
+}
+
+func TestParseSCTE35SectionEncrypted(t *testing.T) {
+	b := scte35Bytes(SCTE35SpliceCommandTypeSpliceInsert, scte35SpliceInsertBytes(), nil)
+	b[1] |= 0x80 | 0x2<<1 // Encrypted packet and encryption algorithm
+
+	d, err := parsePSIData(astikit.NewBytesIterator(scte35PSIBytes(b)), false)
+	assert.NoError(t, err)
+	assert.Equal(t, &SCTE35Data{
+		CWIndex:             0xff,
+		EncryptedData:       b[10:],
+		EncryptedPacket:     true,
+		EncryptionAlgorithm: 2,
+		PTSAdjustment:       newClockReference(10, 0),
+		SAPType:             1,
+		Tier:                0xfff,
+	}, d.Sections[0].Syntax.Data.SCTE35)
+}
@@ -106,3 +106,70 @@ func parseSDTSection(i *astikit.BytesIterator, offsetSectionsEnd int, tableIDExt
 	}
 	return
 }
+
+func calcSDTSectionLength(d *SDTData) uint16 {
+	ret := uint16(3) // original_network_id, reserved_future_use
+	for _, s := range d.Services {
+		ret += calcSDTServiceLength(s)
+	}
+	return ret
+}
+
+func calcSDTServiceLength(s *SDTDataService) uint16 {
+	return 5 + calcDescriptorsLength(s.Descriptors) // service_id, flags, running_status, free_CA_mode, descriptors_loop_length
+}
+
+func writeSDTSection(w *astikit.BitsWriter, d *SDTData) (int, error) {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(d.OriginalNetworkID)
+	b.Write(uint8(0xff)) // reserved_future_use
+	bytesWritten := 3
+
+	for _, s := range d.Services {
+		b.Write(s.ServiceID)
+		b.WriteN(uint8(0xff), 6) // reserved_future_use
+		b.Write(s.HasEITSchedule)
+		b.Write(s.HasEITPresentFollowing)
+		b.WriteN(s.RunningStatus, 3)
+		b.Write(s.HasFreeCSAMode)
+		b.WriteN(calcDescriptorsLength(s.Descriptors), 12)
+		bytesWritten += 5
+
+		if err := b.Err(); err != nil {
+			return 0, err
+		}
+
+		n, err := writeDescriptors(w, s.Descriptors)
+		if err != nil {
+			return 0, err
+		}
+		bytesWritten += n
+	}
+
+	return bytesWritten, b.Err()
+}
+
+// newSDTSections splits an SDT over as many sections as needed
+func newSDTSections(d *SDTData, tableID PSITableID, versionNumber uint8) ([]*PSISection, error) {
+	var lengths []int
+	for _, s := range d.Services {
+		lengths = append(lengths, int(calcSDTServiceLength(s)))
+	}
+	base := int(calcSDTSectionLength(&SDTData{}))
+	counts, err := splitPSISectionItems(lengths, base, base, psiSectionMaxLength)
+	if err != nil {
+		return nil, err
+	}
+
+	var ss []*PSISection
+	services := d.Services
+	for _, c := range counts {
+		sd := *d
+		sd.Services = services[:c]
+		services = services[c:]
+		ss = append(ss, newPSISection(tableID, d.TransportStreamID, versionNumber, &PSISectionSyntaxData{SDT: &sd}))
+	}
+	setPSISectionNumbers(ss)
+	return ss, nil
+}
@@ -42,3 +42,26 @@ func TestParseSDTSection(t *testing.T) {
 	assert.Equal(t, d, sdt)
 	assert.NoError(t, err)
 }
+
+func TestNewSDTSections(t *testing.T) {
+	d := &SDTData{OriginalNetworkID: 2, TransportStreamID: 1}
+	for i := 0; i < 10; i++ {
+		d.Services = append(d.Services, &SDTDataService{
+			Descriptors: []*Descriptor{{Length: 250, Tag: 0x80, UserDefined: make([]byte, 250)}},
+			ServiceID:   uint16(i),
+		})
+	}
+	ss, err := newSDTSections(d, PSITableIDSDTVariant1, 3)
+	assert.NoError(t, err)
+	assert.Len(t, ss, 4)
+	var services []*SDTDataService
+	for i, s := range ss {
+		assert.Equal(t, uint8(i), s.Syntax.Header.SectionNumber)
+		assert.Equal(t, uint8(3), s.Syntax.Header.LastSectionNumber)
+		assert.Equal(t, uint16(1), s.Syntax.Header.TableIDExtension)
+		assert.Equal(t, uint8(3), s.Syntax.Header.VersionNumber)
+		assert.True(t, s.Header.SectionLength <= psiSectionMaxLength)
+		services = append(services, s.Syntax.Data.SDT.Services...)
+	}
+	assert.Equal(t, d.Services, services)
+}
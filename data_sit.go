@@ -0,0 +1,70 @@
+package astits
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astikit"
+)
+
+// SITData represents a SIT data, which describes the services of partial transport streams
+// Page: 13 | Chapter: 7.1.2 | Link: https://www.dvb.org/resources/public/standards/a38_dvb-si_specification.pdf
+// (barbashov) the link above can be broken, alternative: https://dvb.org/wp-content/uploads/2019/12/a038_tm1217r37_en300468v1_17_1_-_rev-134_-_si_specification.pdf
+type SITData struct {
+	Services                    []*SITDataService
+	TransmissionInfoDescriptors []*Descriptor
+}
+
+// SITDataService represents a SIT data service
+type SITDataService struct {
+	Descriptors   []*Descriptor
+	RunningStatus uint8
+	ServiceID     uint16
+}
+
+// parseSITSection parses a SIT section
+func parseSITSection(i *astikit.BytesIterator, offsetSectionsEnd int) (d *SITData, err error) {
+	// Create data
+	d = &SITData{}
+
+	// Transmission info descriptors
+	if d.TransmissionInfoDescriptors, err = parseDescriptors(i); err != nil {
+		err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+		return
+	}
+
+	// Loop until end of section data is reached
+	for i.Offset() < offsetSectionsEnd {
+		// Get next bytes
+		var bs []byte
+		if bs, err = i.NextBytesNoCopy(2); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+
+		// Create service
+		s := &SITDataService{ServiceID: uint16(bs[0])<<8 | uint16(bs[1])}
+
+		// Get next byte
+		var b byte
+		if b, err = i.NextByte(); err != nil {
+			err = fmt.Errorf("astits: fetching next byte failed: %w", err)
+			return
+		}
+
+		// Running status
+		s.RunningStatus = uint8(b>>4) & 0x7
+
+		// We need to rewind since the current byte is used by the descriptor as well
+		i.Skip(-1)
+
+		// Descriptors
+		if s.Descriptors, err = parseDescriptors(i); err != nil {
+			err = fmt.Errorf("astits: parsing descriptors failed: %w", err)
+			return
+		}
+
+		// Append service
+		d.Services = append(d.Services, s)
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package astits
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+var sit = &SITData{
+	Services: []*SITDataService{{
+		Descriptors:   descriptors,
+		RunningStatus: RunningStatusRunning,
+		ServiceID:     1,
+	}},
+	TransmissionInfoDescriptors: descriptors,
+}
+
+func sitBytes() []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write("1111")     // Reserved for future use
+	descriptorsBytes(w) // Transmission info descriptors
+	w.Write(uint16(1))  // Service #1 id
+	w.Write("1")        // Service #1 reserved for future use
+	w.Write("100")      // Service #1 running status
+	descriptorsBytes(w) // Service #1 descriptors
+	return buf.Bytes()
+}
+
+func TestParseSITSection(t *testing.T) {
+	var b = sitBytes()
+	d, err := parseSITSection(astikit.NewBytesIterator(b), len(b))
+	assert.Equal(t, d, sit)
+	assert.NoError(t, err)
+}
+
+func TestParseSITPSIData(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write(uint8(PSITableIDSIT))          // SIT table ID
+	w.Write("1")                           // SIT syntax section indicator
+	w.Write("1")                           // SIT reserved for future use
+	w.Write("11")                          // SIT reserved
+	w.Write("000000010101")                // SIT section length
+	w.Write(psiSectionSyntaxHeaderBytes()) // SIT syntax section header
+	w.Write(sitBytes())                    // SIT data
+	w.Write(computeCRC32(buf.Bytes()))     // SIT CRC32
+
+	d, err := parsePSIData(astikit.NewBytesIterator(append([]byte{0}, buf.Bytes()...)), false)
+	assert.NoError(t, err)
+	p := &Packet{}
+	assert.Equal(t, []*DemuxerData{{FirstPacket: p, PID: 0x1f, SIT: sit}}, d.toData(p, 0x1f))
+}
@@ -0,0 +1,50 @@
+package astits
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asticode/go-astikit"
+)
+
+// TDTData represents a TDT data
+// Page: 39 | Chapter: 5.2.5 | Link: https://www.dvb.org/resources/public/standards/a38_dvb-si_specification.pdf
+// (barbashov) the link above can be broken, alternative: https://dvb.org/wp-content/uploads/2019/12/a038_tm1217r37_en300468v1_17_1_-_rev-134_-_si_specification.pdf
+type TDTData struct {
+	UTCTime time.Time
+}
+
+// parseTDTSection parses a TDT section
+func parseTDTSection(i *astikit.BytesIterator) (d *TDTData, err error) {
+	// Create data
+	d = &TDTData{}
+
+	// UTC time
+	if d.UTCTime, err = parseDVBTime(i); err != nil {
+		err = fmt.Errorf("astits: parsing DVB time failed: %w", err)
+		return
+	}
+	return
+}
+
+func calcTDTSectionLength(d *TDTData) uint16 {
+	return 5 // UTC_time
+}
+
+func writeTDTSection(w *astikit.BitsWriter, d *TDTData) (int, error) {
+	return writeDVBTime(w, d.UTCTime)
+}
+
+// newTDTSection creates a TDT section
+func newTDTSection(d *TDTData) *PSISection {
+	s := &PSISection{
+		Header: &PSISectionHeader{
+			PrivateBit: true, // reserved_future_use
+			TableID:    PSITableIDTDT,
+			TableType:  PSITableTypeTDT,
+		},
+		Syntax: &PSISectionSyntax{Data: &PSISectionSyntaxData{TDT: d}},
+	}
+	s.Header.SectionLength = calcPSISectionLength(s)
+	return s
+}
@@ -0,0 +1,14 @@
+package astits
+
+import (
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTDTSection(t *testing.T) {
+	d, err := parseTDTSection(astikit.NewBytesIterator(dvbTimeBytes))
+	assert.Equal(t, d, &TDTData{UTCTime: dvbTime})
+	assert.NoError(t, err)
+}
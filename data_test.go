@@ -20,15 +20,21 @@ func TestParseData(t *testing.T) {
 		skip = true
 		return
 	}
-	ds, err := parseData(ps, c, pm)
+	ds, err := parseData(ps, c, pm, nil, nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, cds, ds)
 
-	// Do nothing for CAT
-	ps = []*Packet{{Header: PacketHeader{PID: PIDCAT}}}
-	ds, err = parseData(ps, nil, pm)
+	// CAT
+	buf := &bytes.Buffer{}
+	_, err = writePSIData(astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf}), &PSIData{Sections: []*PSISection{
+		newPSISection(PSITableIDCAT, 0xffff, 0, &PSISectionSyntaxData{CAT: cat}),
+	}})
+	assert.NoError(t, err)
+	ps = []*Packet{{Header: PacketHeader{PID: PIDCAT}, Payload: buf.Bytes()}}
+	ds, err = parseData(ps, nil, pm, nil, nil, nil)
 	assert.NoError(t, err)
-	assert.Empty(t, ds)
+	assert.Len(t, ds, 1)
+	assert.Equal(t, cat, ds[0].CAT)
 
 	// PES
 	p := pesWithHeaderBytes()
@@ -42,7 +48,7 @@ func TestParseData(t *testing.T) {
 			Payload: p[33:],
 		},
 	}
-	ds, err = parseData(ps, nil, pm)
+	ds, err = parseData(ps, nil, pm, nil, nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, []*DemuxerData{
 		{
@@ -64,7 +70,7 @@ func TestParseData(t *testing.T) {
 			Payload: p[33:],
 		},
 	}
-	ds, err = parseData(ps, nil, pm)
+	ds, err = parseData(ps, nil, pm, nil, nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, psi.toData(
 		&Packet{Header: ps[0].Header, AdaptationField: ps[0].AdaptationField},
@@ -80,7 +86,7 @@ func TestIsPSIPayload(t *testing.T) {
 			pids = append(pids, i)
 		}
 	}
-	assert.Equal(t, []int{0, 16, 17, 18, 19, 20, 30, 31}, pids)
+	assert.Equal(t, []int{0, 1, 16, 17, 18, 19, 20, 30, 31}, pids)
 	pm.setUnlocked(uint16(1), uint16(0))
 	assert.True(t, isPSIPayload(uint16(1), pm))
 }
@@ -94,3 +100,14 @@ func TestIsPESPayload(t *testing.T) {
 	w.Write("000000000000000000000001")
 	assert.True(t, isPESPayload(buf.Bytes()))
 }
+
+func TestIsPESComplete(t *testing.T) {
+	assert.False(t, isPESComplete([]*Packet{}))
+	assert.False(t, isPESComplete([]*Packet{{Payload: []byte{0, 0, 1, 0xc0, 0, 2, 1, 2}}}))
+	assert.False(t, isPESComplete([]*Packet{{Header: PacketHeader{PayloadUnitStartIndicator: true}, Payload: []byte{0, 0, 1, 0xe0, 0, 0, 1, 2}}}))
+	assert.False(t, isPESComplete([]*Packet{{Header: PacketHeader{PayloadUnitStartIndicator: true}, Payload: []byte{0, 0, 1, 0xc0, 0, 3, 1, 2}}}))
+	assert.True(t, isPESComplete([]*Packet{
+		{Header: PacketHeader{PayloadUnitStartIndicator: true}, Payload: []byte{0, 0, 1, 0xc0, 0, 3, 1, 2}},
+		{Payload: []byte{3}},
+	}))
+}
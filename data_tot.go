@@ -33,3 +33,36 @@ func parseTOTSection(i *astikit.BytesIterator) (d *TOTData, err error) {
 	}
 	return
 }
+
+func calcTOTSectionLength(d *TOTData) uint16 {
+	return 7 + calcDescriptorsLength(d.Descriptors) // UTC_time, descriptors_loop_length
+}
+
+func writeTOTSection(w *astikit.BitsWriter, d *TOTData) (int, error) {
+	bytesWritten, err := writeDVBTime(w, d.UTCTime)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := writeDescriptorsWithLength(w, d.Descriptors)
+	if err != nil {
+		return 0, err
+	}
+	bytesWritten += n
+
+	return bytesWritten, nil
+}
+
+// newTOTSection creates a TOT section
+func newTOTSection(d *TOTData) *PSISection {
+	s := &PSISection{
+		Header: &PSISectionHeader{
+			PrivateBit: true, // reserved_future_use
+			TableID:    PSITableIDTOT,
+			TableType:  PSITableTypeTOT,
+		},
+		Syntax: &PSISectionSyntax{Data: &PSISectionSyntaxData{TOT: d}},
+	}
+	s.Header.SectionLength = calcPSISectionLength(s)
+	return s
+}
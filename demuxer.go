@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/asticode/go-astikit"
 )
@@ -18,23 +19,56 @@ var (
 	ErrPacketMustStartWithASyncByte = errors.New("astits: packet must start with a sync byte")
 )
 
+// DataError represents an error that occurred while parsing the payload of a set of packets
+type DataError struct {
+	Err    error
+	Offset int64 // Offset of the first packet of the payload in the stream
+	PID    uint16
+}
+
+// Error implements the error interface
+func (e *DataError) Error() string {
+	return fmt.Sprintf("astits: parsing data of PID %d at offset %d failed: %s", e.PID, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *DataError) Unwrap() error {
+	return e.Err
+}
+
 // Demuxer represents a demuxer
 // https://en.wikipedia.org/wiki/MPEG_transport_stream
 // http://seidl.cs.vsb.cz/download/dvb/DVB_Poster.pdf
 // http://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.13.01_40/en_300468v011301o.pdf
 type Demuxer struct {
-	ctx        context.Context
-	dataBuffer []*DemuxerData
-	l          astikit.CompleteLogger
+	ctx          context.Context
+	dataBuffer   []*DemuxerData
+	invalidCRC32 func(pid uint16, s *PSISection)
+	l            astikit.CompleteLogger
 
-	optPacketSize    int
-	optPacketsParser PacketsParser
-	optPacketSkipper PacketSkipper
+	optAdaptationFieldData     bool
+	optDataErrorHandler        func(err *DataError)
+	optPacketSize              int
+	optPacketsParser           PacketsParser
+	optPacketSkipper           PacketSkipper
+	optPSITableAssembly        bool
+	optPSITableAssemblyTimeout time.Duration
+	optPSITableChanges         bool
+	optReedSolomonCorrection   bool
+	optSyncLockPackets         int
+	optSyncLossPackets         int
+	optTolerateInvalidPSICRC32 bool
 
-	packetBuffer *packetBuffer
-	packetPool   *packetPool
-	programMap   *programMap
-	r            io.Reader
+	packetBuffer      *packetBuffer
+	packetPool        *packetPool
+	payloadOffsets    map[uint32]int64 // Offsets of the first packet of the payloads being pooled, indexed by PID
+	pcrClocks         *streamClocks
+	programMap        *programMap
+	psiTableAssembler *psiTableAssembler
+	psiTableTracker   *psiTableTracker
+	r                 io.Reader
+	rctx              io.Reader // Reads return as soon as the ctx is done, even if a read is blocking
+	stats             *demuxerStats
 }
 
 // PacketsParser represents an object capable of parsing a set of packets containing a unique payload spanning over those packets
@@ -49,10 +83,14 @@ type PacketSkipper func(p *Packet) (skip bool)
 func NewDemuxer(ctx context.Context, r io.Reader, opts ...func(*Demuxer)) (d *Demuxer) {
 	// Init
 	d = &Demuxer{
-		ctx:        ctx,
-		l:          astikit.AdaptStdLogger(nil),
-		programMap: newProgramMap(),
-		r:          r,
+		ctx:            ctx,
+		l:              astikit.AdaptStdLogger(nil),
+		payloadOffsets: make(map[uint32]int64),
+		pcrClocks:      newStreamClocks(),
+		programMap:     newProgramMap(),
+		r:              r,
+		rctx:           newCtxReader(ctx, r),
+		stats:          newDemuxerStats(),
 	}
 	d.packetPool = newPacketPool(d.programMap)
 
@@ -61,9 +99,43 @@ func NewDemuxer(ctx context.Context, r io.Reader, opts ...func(*Demuxer)) (d *De
 		opt(d)
 	}
 
+	// Create PSI table assembler
+	if d.optPSITableAssembly {
+		d.psiTableAssembler = newPSITableAssembler(d.optPSITableAssemblyTimeout, d.pcrClocks)
+	}
+
+	// Create PSI table tracker
+	if d.optPSITableChanges {
+		d.psiTableTracker = newPSITableTracker()
+	}
+
+	// Report invalid PSI CRC32s instead of failing
+	if d.optTolerateInvalidPSICRC32 {
+		d.invalidCRC32 = func(pid uint16, s *PSISection) {
+			d.stats.addPSICRC32Error(pid)
+			d.l.Warnf("astits: PSI section with table ID %#x on PID %d has an invalid CRC32 %#x", s.Header.TableID, pid, s.CRC32)
+		}
+	}
 	return
 }
 
+// DemuxerOptAdaptationFieldData returns the option to return the adaptation field of packets without payload,
+// such as packets carrying only the PCR, in DemuxerData.AdaptationField
+func DemuxerOptAdaptationFieldData() func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optAdaptationFieldData = true
+	}
+}
+
+// DemuxerOptDataErrorHandler returns the option to keep on demuxing when the payload of a set of packets can't be
+// parsed. Instead of being returned by NextData, the error is provided to the handler along with the PID and the
+// offset of the payload, and NextData moves on to the next payload.
+func DemuxerOptDataErrorHandler(h func(err *DataError)) func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optDataErrorHandler = h
+	}
+}
+
 // DemuxerOptLogger returns the option to set the logger
 func DemuxerOptLogger(l astikit.StdLogger) func(*Demuxer) {
 	return func(d *Demuxer) {
@@ -92,30 +164,116 @@ func DemuxerOptPacketSkipper(s PacketSkipper) func(*Demuxer) {
 	}
 }
 
+// DemuxerOptPSITableAssembly returns the option to return PSI tables split over several sections only once every
+// section of a (table_id, table_id_extension, version_number) has been received, merged into one table.
+// Tables that are still incomplete after timeout of stream time, based on the PCRs, are discarded. If timeout is 0,
+// it defaults to 30s. When the stream has no PCR, the timeout is converted into a number of packets at 100Mbps.
+// Sections whose current_next_indicator is not set describe a table that is not applicable yet: they are
+// dropped since they are retransmitted with the indicator set once the table becomes applicable.
+func DemuxerOptPSITableAssembly(timeout time.Duration) func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optPSITableAssembly = true
+		d.optPSITableAssemblyTimeout = timeout
+	}
+}
+
+// DemuxerOptPSITableChanges returns the option to return PSI tables only when they first appear or when their
+// version number or CRC32 changes. DemuxerData.TableChange then describes what has been added, removed or modified.
+// Without DemuxerOptPSITableAssembly, each section of a table split over several sections is tracked on its own.
+func DemuxerOptPSITableChanges() func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optPSITableChanges = true
+	}
+}
+
+// DemuxerOptReedSolomonCorrection returns the option to correct 204-byte packets using their Reed-Solomon parity
+// bytes before parsing them. Packets that can't be corrected get their transport error indicator set.
+func DemuxerOptReedSolomonCorrection() func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optReedSolomonCorrection = true
+	}
+}
+
+// DemuxerOptSyncLockPackets returns the option to set the number of consecutive packets starting with a sync byte
+// needed to acquire lock when hunting for the sync byte. Default is 5.
+func DemuxerOptSyncLockPackets(n int) func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optSyncLockPackets = n
+	}
+}
+
+// DemuxerOptSyncLossPackets returns the option to set the number of consecutive packets whose sync byte is corrupted
+// after which lock is lost and the sync byte is hunted for. Packets whose sync byte is corrupted are returned as long
+// as lock is kept. Default is 2.
+func DemuxerOptSyncLossPackets(n int) func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optSyncLossPackets = n
+	}
+}
+
+// DemuxerOptTolerateInvalidPSICRC32 returns the option to return PSI tables whose CRC32 is invalid instead of failing.
+// Such tables are reported through the logger and the stats, and are marked as unverified in DemuxerData.Unverified.
+func DemuxerOptTolerateInvalidPSICRC32() func(*Demuxer) {
+	return func(d *Demuxer) {
+		d.optTolerateInvalidPSICRC32 = true
+	}
+}
+
+// SkippedBytes returns the number of bytes that have been skipped so far while hunting for the sync byte
+func (dmx *Demuxer) SkippedBytes() int64 {
+	return dmx.stats.getSkippedBytes()
+}
+
+// Stats returns a snapshot of the demuxer statistics
+func (dmx *Demuxer) Stats() DemuxerStats {
+	return dmx.stats.snapshot()
+}
+
 // NextPacket retrieves the next packet
 func (dmx *Demuxer) NextPacket() (p *Packet, err error) {
 	// Check ctx error
-	// TODO Handle ctx error another way since if the read blocks, everything blocks
-	// Maybe execute everything in a goroutine and listen the ctx channel in the same for loop
 	if err = dmx.ctx.Err(); err != nil {
 		return
 	}
 
 	// Create packet buffer if not exists
 	if dmx.packetBuffer == nil {
-		if dmx.packetBuffer, err = newPacketBuffer(dmx.r, dmx.optPacketSize, dmx.optPacketSkipper); err != nil {
+		if dmx.packetBuffer, err = newPacketBuffer(dmx.rctx, dmx.optPacketSize, dmx.optSyncLockPackets, dmx.optSyncLossPackets, dmx.optReedSolomonCorrection, dmx.optPacketSkipper); err != nil {
+			// Return the ctx error as is
+			if ctxErr := dmx.ctx.Err(); ctxErr != nil {
+				err = ctxErr
+				return
+			}
 			err = fmt.Errorf("astits: creating packet buffer failed: %w", err)
 			return
 		}
 	}
 
 	// Fetch next packet from buffer
-	if p, err = dmx.packetBuffer.next(); err != nil {
+	p, err = dmx.packetBuffer.next()
+
+	// Bytes have been skipped while hunting for the sync byte
+	if n := dmx.packetBuffer.skipped; n > 0 {
+		dmx.stats.addSkippedBytes(n)
+		dmx.l.Warnf("astits: skipped %d bytes while hunting for the sync byte", n)
+	}
+
+	if err != nil {
+		// Return the ctx error as is
+		if ctxErr := dmx.ctx.Err(); ctxErr != nil {
+			err = ctxErr
+			return
+		}
+
 		if err != ErrNoMorePackets {
 			err = fmt.Errorf("astits: fetching next packet from buffer failed: %w", err)
 		}
 		return
 	}
+
+	// Update stats and stream time
+	dmx.stats.addPacket(p)
+	dmx.pcrClocks.addPacket(p)
 	return
 }
 
@@ -145,14 +303,26 @@ func (dmx *Demuxer) NextData() (d *DemuxerData, err error) {
 
 					// Parse data
 					var errParseData error
-					if ds, errParseData = parseData(ps, dmx.optPacketsParser, dmx.programMap); errParseData != nil {
+					if ds, errParseData = parseData(ps, dmx.optPacketsParser, dmx.programMap, dmx.psiTableAssembler, dmx.psiTableTracker, dmx.invalidCRC32); errParseData != nil {
 						// Log error as there may be some incomplete data here
 						// We still want to try to parse all packets, in case final data is complete
-						dmx.l.Error(fmt.Errorf("astits: parsing data failed: %w", errParseData))
+						dmx.stats.addParseError(ps, dmx.programMap, errParseData)
+						if dmx.optDataErrorHandler != nil {
+							dmx.optDataErrorHandler(&DataError{
+								Err:    errParseData,
+								Offset: dmx.payloadOffsets[uint32(ps[0].Header.PID)],
+								PID:    ps[0].Header.PID,
+							})
+						} else {
+							dmx.l.Error(fmt.Errorf("astits: parsing data failed: %w", errParseData))
+						}
 						continue
 					}
 
 					// Update data
+					if len(ds) == 0 {
+						dmx.stats.addUnparsedPayload(ps, dmx.programMap)
+					}
 					if d = dmx.updateData(ds); d != nil {
 						err = nil
 						return
@@ -160,22 +330,63 @@ func (dmx *Demuxer) NextData() (d *DemuxerData, err error) {
 				}
 				return
 			}
-			err = fmt.Errorf("astits: fetching next packet failed: %w", err)
+			// Return the ctx error as is
+			if err != dmx.ctx.Err() {
+				err = fmt.Errorf("astits: fetching next packet failed: %w", err)
+			}
+			return
+		}
+
+		// Packet only carries an adaptation field
+		if dmx.optAdaptationFieldData && !p.Header.HasPayload && p.Header.HasAdaptationField && !p.Header.TransportErrorIndicator {
+			d = &DemuxerData{
+				AdaptationField: &AdaptationFieldData{
+					AdaptationField: p.AdaptationField,
+					Offset:          dmx.packetBuffer.packetOffset,
+				},
+				FirstPacket: p,
+				PID:         p.Header.PID,
+			}
 			return
 		}
 
+		// Keep track of the offset of the payload the packet starts
+		payloadOffset := dmx.payloadOffsets[uint32(p.Header.PID)]
+		if p.Header.PayloadUnitStartIndicator {
+			dmx.payloadOffsets[uint32(p.Header.PID)] = dmx.packetBuffer.packetOffset
+		}
+
 		// Add packet to the pool
 		if ps = dmx.packetPool.addUnlocked(p); len(ps) == 0 {
 			continue
 		}
 
 		// Parse data
-		if ds, err = parseData(ps, dmx.optPacketsParser, dmx.programMap); err != nil {
+		if ds, err = parseData(ps, dmx.optPacketsParser, dmx.programMap, dmx.psiTableAssembler, dmx.psiTableTracker, dmx.invalidCRC32); err != nil {
+			dmx.stats.addParseError(ps, dmx.programMap, err)
+
+			// Hand the error over and move on to the next payload
+			if dmx.optDataErrorHandler != nil {
+				// Unless the packet is the first packet of the payload, the payload has been started by a previous packet
+				if ps[0] == p {
+					payloadOffset = dmx.packetBuffer.packetOffset
+				}
+				dmx.optDataErrorHandler(&DataError{
+					Err:    err,
+					Offset: payloadOffset,
+					PID:    p.Header.PID,
+				})
+				err = nil
+				continue
+			}
 			err = fmt.Errorf("astits: building new data failed: %w", err)
 			return
 		}
 
 		// Update data
+		if len(ds) == 0 {
+			dmx.stats.addUnparsedPayload(ps, dmx.programMap)
+		}
 		if d = dmx.updateData(ds); d != nil {
 			return
 		}
@@ -192,12 +403,27 @@ func (dmx *Demuxer) updateData(ds []*DemuxerData) (d *DemuxerData) {
 		// Update program map
 		for _, v := range ds {
 			if v.PAT != nil {
+				var numbers []uint16
 				for _, pgm := range v.PAT.Programs {
 					// Program number 0 is reserved to NIT
 					if pgm.ProgramNumber > 0 {
 						dmx.programMap.setUnlocked(pgm.ProgramMapID, pgm.ProgramNumber)
+						numbers = append(numbers, pgm.ProgramNumber)
 					}
 				}
+
+				// Stop parsing SCTE-35 pids of programs that are not in the PAT anymore
+				dmx.programMap.retainSCTE35Unlocked(numbers)
+			}
+
+			if v.PMT != nil {
+				var pids []uint16
+				for _, es := range v.PMT.ElementaryStreams {
+					if isSCTE35ElementaryStream(es) {
+						pids = append(pids, es.ElementaryPID)
+					}
+				}
+				dmx.programMap.setSCTE35Unlocked(v.PMT.ProgramNumber, pids)
 			}
 		}
 	}
@@ -209,7 +435,16 @@ func (dmx *Demuxer) Rewind() (n int64, err error) {
 	dmx.dataBuffer = []*DemuxerData{}
 	dmx.packetBuffer = nil
 	dmx.packetPool = newPacketPool(dmx.programMap)
-	if n, err = rewind(dmx.r); err != nil {
+	dmx.payloadOffsets = make(map[uint32]int64)
+	dmx.pcrClocks = newStreamClocks()
+	if dmx.psiTableAssembler != nil {
+		dmx.psiTableAssembler = newPSITableAssembler(dmx.optPSITableAssemblyTimeout, dmx.pcrClocks)
+	}
+	if dmx.psiTableTracker != nil {
+		dmx.psiTableTracker = newPSITableTracker()
+	}
+	dmx.stats.rewind()
+	if n, err = rewind(dmx.rctx); err != nil {
 		err = fmt.Errorf("astits: rewinding reader failed: %w", err)
 		return
 	}
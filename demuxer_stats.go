@@ -0,0 +1,319 @@
+package astits
+
+import (
+	"errors"
+	"sync"
+)
+
+// PCR gap above which the bitrate estimation starts over
+const statsMaxPCRGap = 10 * 27000000
+
+// Duration of PCR time over which the bitrate is estimated, in 27MHz units
+const statsBitrateWindow = 27000000
+
+// DemuxerStats represents a snapshot of the demuxer statistics
+// Counters only take into account packets returned by the demuxer, which excludes packets filtered out by the
+// packet skipper
+type DemuxerStats struct {
+	Bitrate      float64 // Estimated bitrate in bits per second over the last second of PCR time. 0 if it can't be estimated yet.
+	Packets      int64
+	PIDs         map[uint16]*DemuxerPIDStats
+	SkippedBytes int64 // Bytes skipped while hunting for the sync byte
+}
+
+// DemuxerPIDStats represents a snapshot of the demuxer statistics for a PID
+type DemuxerPIDStats struct {
+	Bitrate                 float64 // Estimated bitrate in bits per second over the last second of PCR time. 0 if it can't be estimated yet.
+	ContinuityCounterErrors int64
+	DuplicatePackets        int64 // Packets with payload whose continuity counter is the same as the previous packet's
+	PESParseErrors          int64 // Payloads of non PSI PIDs that couldn't be parsed, including those without a valid PES start code
+	Packets                 int64
+	PSICRC32Errors          int64
+	ScrambledPackets        int64
+	TransportErrorPackets   int64 // Packets whose transport error indicator is set
+}
+
+// demuxerStats keeps track of the demuxer statistics
+// It's safe for concurrent use so that stats can be retrieved while demuxing
+type demuxerStats struct {
+	m       *sync.Mutex // Locks everything below
+	packets int64
+	// We use map[uint32] instead map[uint16] as go runtime provide optimized hash functions for (u)int32/64 keys
+	pids         map[uint32]*demuxerPIDStats
+	skippedBytes int64
+
+	// Bitrate estimation window
+	bitrate      float64 // Bitrate of the last complete window
+	firstPCR     int64   // In 27MHz units
+	firstPackets int64
+	hasBitrate   bool // Whether a window has been completed since the estimation started
+	hasPCRPID    bool
+	lastPCR      int64 // In 27MHz units
+	lastPackets  int64
+	pcrPID       uint16
+}
+
+// demuxerPIDStats keeps track of the demuxer statistics for a PID
+type demuxerPIDStats struct {
+	DemuxerPIDStats
+	cc           uint8
+	bitrate      float64 // Bitrate of the last complete window
+	firstPackets int64   // Number of packets when the bitrate estimation window started
+	hasCC        bool
+	isDuplicate  bool
+}
+
+// newDemuxerStats creates new demuxer stats
+func newDemuxerStats() *demuxerStats {
+	return &demuxerStats{
+		m:    &sync.Mutex{},
+		pids: make(map[uint32]*demuxerPIDStats),
+	}
+}
+
+// pid returns the stats of a PID and creates them if they don't exist
+// It must be called with the lock held
+func (s *demuxerStats) pid(pid uint16) *demuxerPIDStats {
+	ps, ok := s.pids[uint32(pid)]
+	if !ok {
+		ps = &demuxerPIDStats{}
+		s.pids[uint32(pid)] = ps
+	}
+	return ps
+}
+
+// addPacket updates the stats with a new packet
+func (s *demuxerStats) addPacket(p *Packet) {
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Update counters
+	s.packets++
+	ps := s.pid(p.Header.PID)
+	ps.Packets++
+	if p.Header.TransportErrorIndicator {
+		ps.TransportErrorPackets++
+	}
+	if p.Header.TransportScramblingControl != ScramblingControlNotScrambled {
+		ps.ScrambledPackets++
+	}
+
+	// Check continuity counter
+	// Null packets are not subject to it
+	if p.Header.PID != PIDNull {
+		ps.checkContinuityCounter(p)
+	}
+
+	// Update bitrate estimation window
+	if p.Header.HasAdaptationField && p.AdaptationField != nil && p.AdaptationField.HasPCR && p.AdaptationField.PCR != nil {
+		s.addPCR(p.Header.PID, p.AdaptationField)
+	}
+}
+
+// checkContinuityCounter checks the continuity counter of a new packet
+// https://www.etsi.org/deliver/etsi_tr/101200_101299/101290/01.04.01_60/tr_101290v010401p.pdf (5.2.1 Continuity_count_error)
+func (ps *demuxerPIDStats) checkContinuityCounter(p *Packet) {
+	// First packet or discontinuity
+	cc := p.Header.ContinuityCounter
+	if !ps.hasCC || (p.Header.HasAdaptationField && p.AdaptationField != nil && p.AdaptationField.DiscontinuityIndicator) {
+		ps.cc = cc
+		ps.hasCC = true
+		ps.isDuplicate = false
+		return
+	}
+
+	// Continuity counter is only incremented when there's a payload
+	switch {
+	case !p.Header.HasPayload:
+		if cc != ps.cc {
+			ps.ContinuityCounterErrors++
+		}
+	case cc == ps.cc:
+		// Only one duplicate packet is allowed in a row
+		if ps.isDuplicate {
+			ps.ContinuityCounterErrors++
+		} else {
+			ps.DuplicatePackets++
+			ps.isDuplicate = true
+		}
+		return
+	case cc != (ps.cc+1)%16:
+		ps.ContinuityCounterErrors++
+	}
+	ps.cc = cc
+	ps.isDuplicate = false
+}
+
+// addPCR updates the bitrate estimation window with a new PCR
+func (s *demuxerStats) addPCR(pid uint16, af *PacketAdaptationField) {
+	// Only the first PID carrying a PCR is used
+	if !s.hasPCRPID {
+		s.hasPCRPID = true
+		s.pcrPID = pid
+		s.resetBitrateWindow(af.PCR)
+		return
+	} else if pid != s.pcrPID {
+		return
+	}
+
+	// Start over in case of discontinuity
+	pcr := af.PCR.Base*300 + af.PCR.Extension
+	if af.DiscontinuityIndicator || pcr <= s.lastPCR || pcr-s.lastPCR > statsMaxPCRGap {
+		s.resetBitrateWindow(af.PCR)
+		return
+	}
+
+	// Update window
+	s.lastPCR = pcr
+	s.lastPackets = s.packets
+
+	// Window is complete, a new one starts so that the bitrate follows rate changes
+	if s.lastPCR-s.firstPCR >= statsBitrateWindow {
+		s.bitrate = s.windowBitrate()
+		for _, ps := range s.pids {
+			ps.bitrate = ps.windowBitrate(s.bitrate, s.lastPackets-s.firstPackets)
+		}
+		s.resetBitrateWindow(af.PCR)
+		s.hasBitrate = true
+	}
+}
+
+// windowBitrate returns the bitrate of the window in progress, 0 if it can't be estimated yet
+func (s *demuxerStats) windowBitrate() float64 {
+	if d := s.lastPCR - s.firstPCR; d > 0 {
+		return float64((s.lastPackets-s.firstPackets)*MpegTsPacketSize*8) * 27000000 / float64(d)
+	}
+	return 0
+}
+
+// windowBitrate returns the bitrate of the PID in the window in progress, based on the overall bitrate and number
+// of packets of the window
+func (ps *demuxerPIDStats) windowBitrate(bitrate float64, windowPackets int64) float64 {
+	if windowPackets <= 0 {
+		return 0
+	}
+
+	// Packets received after the end of the window are approximated as being part of it
+	n := ps.Packets - ps.firstPackets
+	if n > windowPackets {
+		n = windowPackets
+	}
+	return bitrate * float64(n) / float64(windowPackets)
+}
+
+// resetBitrateWindow starts a new bitrate estimation window, discarding the previous ones
+func (s *demuxerStats) resetBitrateWindow(pcr *ClockReference) {
+	s.hasBitrate = false
+	s.firstPCR = pcr.Base*300 + pcr.Extension
+	s.firstPackets = s.packets
+	s.lastPCR = s.firstPCR
+	s.lastPackets = s.packets
+	for _, ps := range s.pids {
+		ps.firstPackets = ps.Packets
+	}
+}
+
+// rewind resets the continuity counters and the bitrate estimation window since the stream starts over
+func (s *demuxerStats) rewind() {
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.hasBitrate = false
+	s.hasPCRPID = false
+	s.firstPCR, s.lastPCR = 0, 0
+	s.firstPackets, s.lastPackets = s.packets, s.packets
+	for _, ps := range s.pids {
+		ps.firstPackets = ps.Packets
+		ps.hasCC = false
+	}
+}
+
+// addParseError updates the stats with an error that occurred while parsing the payload of a set of packets
+func (s *demuxerStats) addParseError(ps []*Packet, pm *programMap, err error) {
+	if len(ps) == 0 {
+		return
+	}
+
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	pid := ps[0].Header.PID
+	if errors.Is(err, ErrPSIInvalidCRC32) {
+		s.pid(pid).PSICRC32Errors++
+	} else if !isPSIPayload(pid, pm) {
+		s.pid(pid).PESParseErrors++
+	}
+}
+
+// addUnparsedPayload updates the stats with the payload of a set of packets that has been parsed without error but
+// without returning any data either, which happens when the PES start code of a non PSI PID is damaged
+func (s *demuxerStats) addUnparsedPayload(ps []*Packet, pm *programMap) {
+	if len(ps) == 0 || isPSIPayload(ps[0].Header.PID, pm) || isPESPayload(ps[0].Payload) {
+		return
+	}
+
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.pid(ps[0].Header.PID).PESParseErrors++
+}
+
+// addPSICRC32Error updates the stats with a PSI section whose CRC32 is invalid but tolerated
+func (s *demuxerStats) addPSICRC32Error(pid uint16) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.pid(pid).PSICRC32Errors++
+}
+
+// addSkippedBytes updates the stats with bytes skipped while hunting for the sync byte
+func (s *demuxerStats) addSkippedBytes(n int) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.skippedBytes += int64(n)
+}
+
+// getSkippedBytes returns the number of bytes skipped while hunting for the sync byte
+func (s *demuxerStats) getSkippedBytes() int64 {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.skippedBytes
+}
+
+// snapshot returns a snapshot of the stats
+// The bitrate is the one of the last complete window or, until a window has been completed, the one of the window in
+// progress
+func (s *demuxerStats) snapshot() (o DemuxerStats) {
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Create snapshot
+	o = DemuxerStats{
+		Packets:      s.packets,
+		PIDs:         make(map[uint16]*DemuxerPIDStats, len(s.pids)),
+		SkippedBytes: s.skippedBytes,
+	}
+
+	// Estimate bitrate
+	if s.hasBitrate {
+		o.Bitrate = s.bitrate
+	} else {
+		o.Bitrate = s.windowBitrate()
+	}
+
+	// Loop through PIDs
+	for pid, ps := range s.pids {
+		v := ps.DemuxerPIDStats
+		if s.hasBitrate {
+			v.Bitrate = ps.bitrate
+		} else {
+			v.Bitrate = ps.windowBitrate(o.Bitrate, s.lastPackets-s.firstPackets)
+		}
+		o.PIDs[uint16(pid)] = &v
+	}
+	return
+}
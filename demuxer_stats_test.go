@@ -0,0 +1,160 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDemuxerStatsContinuityCounter(t *testing.T) {
+	s := newDemuxerStats()
+	for _, p := range []*Packet{
+		{Header: PacketHeader{ContinuityCounter: 14, HasPayload: true, PID: 1}},
+		{Header: PacketHeader{ContinuityCounter: 15, HasPayload: true, PID: 1}},
+		{Header: PacketHeader{ContinuityCounter: 15, HasPayload: true, PID: 1}}, // Duplicate
+		{Header: PacketHeader{ContinuityCounter: 0, HasPayload: true, PID: 1}},  // Wrap around
+		{Header: PacketHeader{ContinuityCounter: 0, HasAdaptationField: true, PID: 1}, AdaptationField: &PacketAdaptationField{}},
+		{Header: PacketHeader{ContinuityCounter: 2, HasPayload: true, PID: 1}},                                                    // Error
+		{Header: PacketHeader{ContinuityCounter: 2, HasPayload: true, PID: 1}},                                                    // Duplicate
+		{Header: PacketHeader{ContinuityCounter: 2, HasPayload: true, PID: 1}},                                                    // Error
+		{Header: PacketHeader{ContinuityCounter: 4, HasAdaptationField: true, PID: 1}, AdaptationField: &PacketAdaptationField{}}, // Error
+		{Header: PacketHeader{ContinuityCounter: 9, HasAdaptationField: true, HasPayload: true, PID: 1}, AdaptationField: &PacketAdaptationField{DiscontinuityIndicator: true}},
+		{Header: PacketHeader{ContinuityCounter: 10, HasPayload: true, PID: 1, TransportErrorIndicator: true, TransportScramblingControl: ScramblingControlScrambledWithOddKey}},
+		{Header: PacketHeader{ContinuityCounter: 3, HasPayload: true, PID: PIDNull}},
+		{Header: PacketHeader{ContinuityCounter: 3, HasPayload: true, PID: PIDNull}},
+	} {
+		s.addPacket(p)
+	}
+	s.addSkippedBytes(3)
+	o := s.snapshot()
+	assert.Equal(t, int64(13), o.Packets)
+	assert.Equal(t, int64(3), o.SkippedBytes)
+	assert.Equal(t, &DemuxerPIDStats{
+		ContinuityCounterErrors: 3,
+		DuplicatePackets:        2,
+		Packets:                 11,
+		ScrambledPackets:        1,
+		TransportErrorPackets:   1,
+	}, o.PIDs[1])
+	assert.Equal(t, &DemuxerPIDStats{Packets: 2}, o.PIDs[PIDNull])
+}
+
+func TestDemuxerStatsBitrate(t *testing.T) {
+	s := newDemuxerStats()
+	pcrPacket := func(pid uint16, pcr int64) *Packet {
+		return &Packet{
+			AdaptationField: &PacketAdaptationField{HasPCR: true, PCR: newClockReference(pcr, 0)},
+			Header:          PacketHeader{HasAdaptationField: true, PID: pid},
+		}
+	}
+
+	// No PCR yet
+	s.addPacket(&Packet{Header: PacketHeader{HasPayload: true, PID: 2}})
+	assert.Equal(t, float64(0), s.snapshot().Bitrate)
+
+	// 1 PCR packet, 2 packets on PID 2 and 1 packet on PID 3 over 1s
+	s.addPacket(pcrPacket(1, 90000))
+	s.addPacket(&Packet{Header: PacketHeader{HasPayload: true, PID: 2}})
+	s.addPacket(&Packet{Header: PacketHeader{ContinuityCounter: 1, HasPayload: true, PID: 2}})
+	s.addPacket(&Packet{Header: PacketHeader{HasPayload: true, PID: 3}})
+	s.addPacket(pcrPacket(3, 0)) // Not the PCR PID
+	s.addPacket(pcrPacket(1, 180000))
+	o := s.snapshot()
+	assert.Equal(t, float64(5*MpegTsPacketSize*8), o.Bitrate)
+	assert.Equal(t, float64(2*MpegTsPacketSize*8), o.PIDs[2].Bitrate)
+	assert.Equal(t, float64(2*MpegTsPacketSize*8), o.PIDs[3].Bitrate)
+	assert.Equal(t, float64(MpegTsPacketSize*8), o.PIDs[1].Bitrate)
+
+	// Bitrate of the last complete window is used until the next one is complete
+	for i := 0; i < 9; i++ {
+		s.addPacket(&Packet{Header: PacketHeader{ContinuityCounter: uint8(2 + i), HasPayload: true, PID: 2}})
+	}
+	s.addPacket(pcrPacket(1, 225000))
+	o = s.snapshot()
+	assert.Equal(t, float64(5*MpegTsPacketSize*8), o.Bitrate)
+	assert.Equal(t, float64(2*MpegTsPacketSize*8), o.PIDs[2].Bitrate)
+
+	// Rate changes over the next window
+	s.addPacket(pcrPacket(1, 270000))
+	o = s.snapshot()
+	assert.Equal(t, float64(11*MpegTsPacketSize*8), o.Bitrate)
+	assert.Equal(t, float64(9*MpegTsPacketSize*8), o.PIDs[2].Bitrate)
+	assert.Equal(t, float64(0), o.PIDs[3].Bitrate)
+	assert.Equal(t, float64(2*MpegTsPacketSize*8), o.PIDs[1].Bitrate)
+
+	// PCR goes backwards
+	s.addPacket(pcrPacket(1, 0))
+	assert.Equal(t, float64(0), s.snapshot().Bitrate)
+}
+
+func TestDemuxerStats(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+
+	// PAT with an invalid CRC32
+	w.Write(patPacketBytes(0, 32))
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptPacketSize(MpegTsPacketSize))
+	_, err := dmx.NextData()
+	assert.True(t, errors.Is(err, ErrPSIInvalidCRC32))
+	o := dmx.Stats()
+	assert.Equal(t, int64(1), o.Packets)
+	assert.Equal(t, int64(1), o.PIDs[PIDPAT].PSICRC32Errors)
+
+	// Invalid CRC32 is tolerated
+	dmx = NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptPacketSize(MpegTsPacketSize), DemuxerOptTolerateInvalidPSICRC32())
+	d, err := dmx.NextData()
+	assert.NoError(t, err)
+	assert.True(t, d.Unverified)
+	assert.Equal(t, pat, d.PAT)
+	assert.Equal(t, int64(1), dmx.Stats().PIDs[PIDPAT].PSICRC32Errors)
+
+	// PES start code is damaged
+	buf.Reset()
+	for cc, b := range [][]byte{{0x0, 0x0, 0x2, 0xe0}, {0x0, 0x0, 0x1, 0xe0, 0x0, 0x0, 0x80, 0x80}} {
+		p, _ := packet(PacketHeader{ContinuityCounter: uint8(cc), HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x100}, PacketAdaptationField{}, b, false)
+		w.Write(p)
+	}
+	dmx = NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptPacketSize(MpegTsPacketSize))
+	for err == nil {
+		_, err = dmx.NextData()
+	}
+	assert.Equal(t, int64(1), dmx.Stats().PIDs[0x100].PESParseErrors)
+}
+
+func TestDemuxerStatsConcurrency(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	for i := 0; i < 100; i++ {
+		w.Write(patPacketBytes(uint8(i%16), 0x60739f61))
+	}
+
+	// Stats are retrieved while demuxing
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptPacketSize(MpegTsPacketSize))
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				dmx.Stats()
+				dmx.SkippedBytes()
+			}
+		}
+	}()
+	var err error
+	for err == nil {
+		_, err = dmx.NextData()
+	}
+	close(done)
+	<-stopped
+	assert.True(t, errors.Is(err, ErrNoMorePackets))
+	assert.Equal(t, int64(100), dmx.Stats().Packets)
+}
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
 	"testing"
+	"time"
 	"unicode"
 
 	"github.com/asticode/go-astikit"
@@ -71,6 +73,29 @@ func TestDemuxerNextPacket(t *testing.T) {
 	assert.EqualError(t, err, ErrNoMorePackets.Error())
 }
 
+func TestDemuxerNextPacketBlockingRead(t *testing.T) {
+	// Cancel
+	r, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	dmx := NewDemuxer(ctx, r, DemuxerOptPacketSize(188))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err := dmx.NextPacket()
+	assert.Equal(t, context.Canceled, err)
+	_, err = dmx.NextData()
+	assert.Equal(t, context.Canceled, err)
+
+	// Deadline
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	dmx = NewDemuxer(ctx, r)
+	_, err = dmx.NextData()
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
 func TestDemuxerNextData(t *testing.T) {
 	// Init
 	buf := &bytes.Buffer{}
@@ -158,11 +183,151 @@ func TestDemuxerNextDataPATPMT(t *testing.T) {
 	assert.NotNil(t, d.PMT)
 }
 
+func TestDemuxerNextDataPSISectionTail(t *testing.T) {
+	// Sections
+	section := func(transportStreamID uint16) []byte {
+		buf := &bytes.Buffer{}
+		w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+		d := &PATData{Programs: []*PATProgram{{ProgramMapID: 0x100, ProgramNumber: 1}}, TransportStreamID: transportStreamID}
+		_, err := writePSISection(w, &PSISection{
+			Header: &PSISectionHeader{
+				SectionLength:          calcPATSectionLength(d),
+				SectionSyntaxIndicator: true,
+				TableID:                PSITableIDPAT,
+			},
+			Syntax: &PSISectionSyntax{
+				Data:   &PSISectionSyntaxData{PAT: d},
+				Header: &PSISectionSyntaxHeader{CurrentNextIndicator: true, TableIDExtension: transportStreamID},
+			},
+		})
+		assert.NoError(t, err)
+		return buf.Bytes()
+	}
+	s1, s2, s3 := section(1), section(2), section(3)
+
+	// Packets
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	for idx, payload := range [][]byte{
+		append([]byte{0}, s1[:10]...),
+		append(append(append([]byte{uint8(len(s1) - 10)}, s1[10:]...), s2...), s3...),
+	} {
+		_, err := writePacket(w, &Packet{
+			AdaptationField: newStuffingAdaptationField(MpegTsPacketSize - 4 - len(payload)),
+			Header: PacketHeader{
+				ContinuityCounter:         uint8(idx),
+				HasAdaptationField:        true,
+				HasPayload:                true,
+				PayloadUnitStartIndicator: true,
+				PID:                       PIDPAT,
+			},
+			Payload: payload,
+		}, MpegTsPacketSize)
+		assert.NoError(t, err)
+	}
+
+	// Next data
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for _, transportStreamID := range []uint16{1, 2, 3} {
+		d, err := dmx.NextData()
+		assert.NoError(t, err)
+		assert.NotNil(t, d.PAT)
+		assert.Equal(t, transportStreamID, d.PAT.TransportStreamID)
+	}
+}
+
+func TestDemuxerNextDataAdaptationField(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write([]byte{0x1, 0x2}) // Garbage
+	af := &PacketAdaptationField{
+		HasPCR:         true,
+		PCR:            newClockReference(1, 2),
+		StuffingLength: MpegTsPacketSize - 4 - 2 - pcrBytesSize,
+	}
+	for cc := uint8(0); cc < 2; cc++ {
+		_, err := writePacket(w, &Packet{
+			AdaptationField: af,
+			Header: PacketHeader{
+				ContinuityCounter:  cc,
+				HasAdaptationField: true,
+				PID:                0x100,
+			},
+		}, MpegTsPacketSize)
+		assert.NoError(t, err)
+	}
+
+	// Option is disabled
+	_, err := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes())).NextData()
+	assert.EqualError(t, err, ErrNoMorePackets.Error())
+
+	// Option is enabled
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptPacketSize(MpegTsPacketSize), DemuxerOptAdaptationFieldData())
+	for _, offset := range []int64{2, 190} {
+		d, err := dmx.NextData()
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(0x100), d.PID)
+		assert.NotNil(t, d.AdaptationField)
+		assert.Equal(t, offset, d.AdaptationField.Offset)
+		assert.Equal(t, af.PCR, d.AdaptationField.AdaptationField.PCR)
+	}
+}
+
+func patPacketBytes(cc uint8, crc32 uint32) []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	h := PacketHeader{ContinuityCounter: cc, PayloadUnitStartIndicator: true}
+	w.Write(uint8(syncByte))                                        // Sync byte
+	w.Write(packetHeaderBytes(h, "01"))                             // Header
+	w.Write(uint8(0))                                               // Pointer field
+	w.Write(uint8(0))                                               // PAT table ID
+	w.Write("1")                                                    // PAT syntax section indicator
+	w.Write("1")                                                    // PAT private bit
+	w.Write("11")                                                   // PAT reserved
+	w.Write("000000010001")                                         // PAT section length
+	w.Write(psiSectionSyntaxHeaderBytes())                          // PAT syntax section header
+	w.Write(patBytes())                                             // PAT data
+	w.Write(crc32)                                                  // PAT CRC32
+	w.Write(bytes.Repeat([]byte{0xff}, MpegTsPacketSize-buf.Len())) // Stuffing
+	return buf.Bytes()
+}
+
+func TestDemuxerNextDataErrorHandler(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write(patPacketBytes(0, 0x60739f61))
+	w.Write(patPacketBytes(1, 32)) // Invalid CRC32
+	w.Write(patPacketBytes(2, 0x60739f61))
+
+	// Without handler
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	d, err := dmx.NextData()
+	assert.NoError(t, err)
+	assert.Equal(t, pat, d.PAT)
+	_, err = dmx.NextData()
+	assert.Error(t, err)
+
+	// With handler
+	var es []*DataError
+	dmx = NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptDataErrorHandler(func(err *DataError) { es = append(es, err) }))
+	for range []int{0, 1} {
+		d, err = dmx.NextData()
+		assert.NoError(t, err)
+		assert.Equal(t, pat, d.PAT)
+	}
+	assert.Len(t, es, 1)
+	assert.Equal(t, PIDPAT, es[0].PID)
+	assert.Equal(t, int64(MpegTsPacketSize), es[0].Offset)
+	assert.True(t, errors.Is(es[0], ErrPSIInvalidCRC32))
+}
+
 func TestDemuxerRewind(t *testing.T) {
 	r := bytes.NewReader([]byte("content"))
-	dmx := NewDemuxer(context.Background(), r)
+	dmx := NewDemuxer(context.Background(), r, DemuxerOptPSITableAssembly(0))
 	dmx.packetPool.addUnlocked(&Packet{Header: PacketHeader{PID: 1}})
 	dmx.dataBuffer = append(dmx.dataBuffer, &DemuxerData{})
+	dmx.pcrClocks.update(1, 27000000)
+	dmx.psiTableAssembler.add(sdtSection(0, 1, 1, 2), &Packet{}, PIDSDT)
 	b := make([]byte, 2)
 	_, err := r.Read(b)
 	assert.NoError(t, err)
@@ -173,6 +338,9 @@ func TestDemuxerRewind(t *testing.T) {
 	assert.Equal(t, 0, len(dmx.dataBuffer))
 	assert.Equal(t, 0, len(dmx.packetPool.b))
 	assert.Nil(t, dmx.packetBuffer)
+	assert.False(t, dmx.pcrClocks.hasTime())
+	assert.Empty(t, dmx.psiTableAssembler.tables)
+	assert.Equal(t, dmx.pcrClocks, dmx.psiTableAssembler.c)
 }
 
 func BenchmarkDemuxer_NextData(b *testing.B) {
@@ -211,3 +379,59 @@ func FuzzDemuxer(f *testing.F) {
 		}
 	})
 }
+
+func TestDemuxerUpdateDataSCTE35(t *testing.T) {
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(nil))
+	dmx.updateData([]*DemuxerData{
+		{PAT: &PATData{Programs: []*PATProgram{{ProgramMapID: 0x1000, ProgramNumber: 1}, {ProgramMapID: 0x1001, ProgramNumber: 2}}}},
+		{PMT: &PMTData{ProgramNumber: 1, ElementaryStreams: []*PMTElementaryStream{{ElementaryPID: 0x1f0, StreamType: StreamTypeSCTE35}}}},
+		{PMT: &PMTData{ProgramNumber: 2, ElementaryStreams: []*PMTElementaryStream{
+			{
+				ElementaryPID:               0x2f0,
+				ElementaryStreamDescriptors: []*Descriptor{{Registration: &DescriptorRegistration{FormatIdentifier: scte35Identifier}, Tag: DescriptorTagRegistration}},
+				StreamType:                  StreamTypePrivateData,
+			},
+			{ElementaryPID: 0x2f1, StreamType: StreamTypePrivateData},
+		}}},
+	})
+	assert.True(t, dmx.programMap.isSCTE35Unlocked(0x1f0))
+	assert.True(t, dmx.programMap.isSCTE35Unlocked(0x2f0))
+	assert.False(t, dmx.programMap.isSCTE35Unlocked(0x2f1))
+
+	// Program has been removed from the PAT
+	dmx.updateData([]*DemuxerData{{PAT: &PATData{Programs: []*PATProgram{{ProgramMapID: 0x1001, ProgramNumber: 2}}}}})
+	assert.False(t, dmx.programMap.isSCTE35Unlocked(0x1f0))
+	assert.True(t, dmx.programMap.isSCTE35Unlocked(0x2f0))
+}
+
+func TestDemuxerNextDataSCTE35(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(context.Background(), buf)
+	assert.NoError(t, mx.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x1f0, StreamType: StreamTypeSCTE35}))
+	mx.SetPCRPID(0x1f0)
+	_, err := mx.WriteTables()
+	assert.NoError(t, err)
+	_, err = mx.WriteData(&MuxerData{PID: 0x1f0, PrivateSections: []*PrivateSection{{
+		Data:     scte35Bytes(SCTE35SpliceCommandTypeSpliceInsert, scte35SpliceInsertBytes(), scte35DescriptorsBytes()),
+		HasCRC32: true,
+		TableID:  PSITableIDSCTE35,
+	}}})
+	assert.NoError(t, err)
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptPacketSize(MpegTsPacketSize))
+	var d *DemuxerData
+	for {
+		d, err = dmx.NextData()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if d.SCTE35 != nil {
+			break
+		}
+	}
+	assert.NotNil(t, d)
+	assert.Equal(t, uint16(0x1f0), d.PID)
+	assert.Equal(t, scte35SpliceInsert, d.SCTE35.SpliceInsert)
+	assert.Equal(t, scte35Descriptors, d.SCTE35.Descriptors)
+}
@@ -30,6 +30,7 @@ const (
 const (
 	DescriptorTagAC3                        = 0x6a
 	DescriptorTagAVCVideo                   = 0x28
+	DescriptorTagCA                         = 0x9
 	DescriptorTagComponent                  = 0x50
 	DescriptorTagContent                    = 0x54
 	DescriptorTagDataStreamAlignment        = 0x6
@@ -91,6 +92,7 @@ const (
 type Descriptor struct {
 	AC3                        *DescriptorAC3
 	AVCVideo                   *DescriptorAVCVideo
+	CA                         *DescriptorCA
 	Component                  *DescriptorComponent
 	Content                    *DescriptorContent
 	DataStreamAlignment        *DescriptorDataStreamAlignment
@@ -256,6 +258,40 @@ func newDescriptorAVCVideo(i *astikit.BytesIterator) (d *DescriptorAVCVideo, err
 	return
 }
 
+// DescriptorCA represents a conditional access descriptor
+// In the CAT, the CA PID is the PID of the EMMs of the CA system. In a PMT, it's the PID of the ECMs of the CA
+// system for the program or the elementary stream it describes.
+// Page: 69 | Chapter: 2.6.16 | Link: https://www.itu.int/rec/T-REC-H.222.0
+type DescriptorCA struct {
+	CAPID       uint16
+	CASystemID  uint16
+	PrivateData []byte
+}
+
+func newDescriptorCA(i *astikit.BytesIterator, offsetEnd int) (d *DescriptorCA, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(4); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create descriptor
+	d = &DescriptorCA{
+		CAPID:      uint16(bs[2]&0x1f)<<8 | uint16(bs[3]),
+		CASystemID: uint16(bs[0])<<8 | uint16(bs[1]),
+	}
+
+	// Private data
+	if i.Offset() < offsetEnd {
+		if d.PrivateData, err = i.NextBytes(offsetEnd - i.Offset()); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+	}
+	return
+}
+
 // DescriptorComponent represents a component descriptor
 // Chapter: 6.2.8 | Link: https://www.etsi.org/deliver/etsi_en/300400_300499/300468/01.15.01_60/en_300468v011501p.pdf
 type DescriptorComponent struct {
@@ -1277,7 +1313,15 @@ func parseDescriptors(i *astikit.BytesIterator) (o []*Descriptor, err error) {
 	// Get length
 	length := int(uint16(bs[0]&0xf)<<8 | uint16(bs[1]))
 
+	// Parse descriptors
+	return parseDescriptorsLoop(i, length)
+}
+
+// parseDescriptorsLoop parses a loop of descriptors of the given length, for loops whose length is not right before
+// them
+func parseDescriptorsLoop(i *astikit.BytesIterator, length int) (o []*Descriptor, err error) {
 	// Loop
+	var bs []byte
 	if length > 0 {
 		offsetEnd := i.Offset() + length
 		for i.Offset() < offsetEnd {
@@ -1319,6 +1363,11 @@ func parseDescriptors(i *astikit.BytesIterator) (o []*Descriptor, err error) {
 							err = fmt.Errorf("astits: parsing AVC Video descriptor failed: %w", err)
 							return
 						}
+					case DescriptorTagCA:
+						if d.CA, err = newDescriptorCA(i, offsetDescriptorEnd); err != nil {
+							err = fmt.Errorf("astits: parsing CA descriptor failed: %w", err)
+							return
+						}
 					case DescriptorTagComponent:
 						if d.Component, err = newDescriptorComponent(i, offsetDescriptorEnd); err != nil {
 							err = fmt.Errorf("astits: parsing Component descriptor failed: %w", err)
@@ -1524,6 +1573,21 @@ func writeDescriptorAVCVideo(w *astikit.BitsWriter, d *DescriptorAVCVideo) error
 	return b.Err()
 }
 
+func calcDescriptorCALength(d *DescriptorCA) uint8 {
+	return uint8(4 + len(d.PrivateData))
+}
+
+func writeDescriptorCA(w *astikit.BitsWriter, d *DescriptorCA) error {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.Write(d.CASystemID)
+	b.WriteN(uint8(0xff), 3)
+	b.WriteN(d.CAPID, 13)
+	b.Write(d.PrivateData)
+
+	return b.Err()
+}
+
 func calcDescriptorComponentLength(d *DescriptorComponent) uint8 {
 	return uint8(6 + len(d.Text))
 }
@@ -2007,6 +2071,8 @@ func calcDescriptorLength(d *Descriptor) uint8 {
 		return calcDescriptorAC3Length(d.AC3)
 	case DescriptorTagAVCVideo:
 		return calcDescriptorAVCVideoLength(d.AVCVideo)
+	case DescriptorTagCA:
+		return calcDescriptorCALength(d.CA)
 	case DescriptorTagComponent:
 		return calcDescriptorComponentLength(d.Component)
 	case DescriptorTagContent:
@@ -2077,6 +2143,8 @@ func writeDescriptor(w *astikit.BitsWriter, d *Descriptor) (int, error) {
 		return written, writeDescriptorAC3(w, d.AC3)
 	case DescriptorTagAVCVideo:
 		return written, writeDescriptorAVCVideo(w, d.AVCVideo)
+	case DescriptorTagCA:
+		return written, writeDescriptorCA(w, d.CA)
 	case DescriptorTagComponent:
 		return written, writeDescriptorComponent(w, d.Component)
 	case DescriptorTagContent:
@@ -58,6 +58,25 @@ var descriptorTestTable = []descriptorTest{
 				MainID:           uint8(3),
 			}},
 	},
+	{
+		"CA",
+		func(w *astikit.BitsWriter) {
+			w.Write(uint8(DescriptorTagCA)) // Tag
+			w.Write(uint8(6))               // Length
+			w.Write(uint16(0x500))          // CA system ID
+			w.Write("111")                  // Reserved
+			w.Write("0000111111111")        // CA PID
+			w.Write([]byte("pd"))           // Private data
+		},
+		Descriptor{
+			Tag:    DescriptorTagCA,
+			Length: 6,
+			CA: &DescriptorCA{
+				CAPID:       0x1ff,
+				CASystemID:  0x500,
+				PrivateData: []byte("pd"),
+			}},
+	},
 	{
 		"ISO639LanguageAndAudioType",
 		func(w *astikit.BitsWriter) {
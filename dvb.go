@@ -33,7 +33,7 @@ func parseDVBTime(i *astikit.BytesIterator) (t time.Time, err error) {
 	}
 	var y = yt + k
 	var m = mt - 1 - k*12
-	t, _ = time.Parse("06-01-02", fmt.Sprintf("%d-%d-%d", y, m, d))
+	t = time.Date(1900+y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
 
 	// Time
 	var s time.Duration
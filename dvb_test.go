@@ -22,6 +22,11 @@ func TestParseDVBTime(t *testing.T) {
 	d, err := parseDVBTime(astikit.NewBytesIterator(dvbTimeBytes))
 	assert.Equal(t, dvbTime, d)
 	assert.NoError(t, err)
+
+	// After 1999
+	d, err = parseDVBTime(astikit.NewBytesIterator([]byte{0xec, 0x18, 0x08, 0x05, 0x09})) // EC18080509
+	assert.Equal(t, time.Date(2024, 5, 10, 8, 5, 9, 0, time.UTC), d)
+	assert.NoError(t, err)
 }
 
 func TestParseDVBDurationMinutes(t *testing.T) {
@@ -5,6 +5,9 @@ import (
 	"context"
 	"errors"
 	"io"
+	"math/bits"
+	"sort"
+	"time"
 
 	"github.com/asticode/go-astikit"
 )
@@ -13,52 +16,141 @@ const (
 	startPID           uint16 = 0x0100
 	pmtStartPID        uint16 = 0x1000
 	programNumberStart uint16 = 1
+
+	// Maximum interval between automatic PCRs
+	maxPCRInterval = 40 * time.Millisecond
+
+	// Default delay between the moment a PES packet is written and its DTS
+	defaultMuxDelay = 500 * time.Millisecond
+
+	// Maximum section length of PSI sections
+	psiSectionMaxLength = 1021
+	// Maximum number of programs in a PAT section: the section length includes the syntax header and the CRC32
+	patMaxProgramsPerSection = (psiSectionMaxLength - 5 - 4) / patSectionEntryBytesSize
 )
 
 var (
-	ErrPIDNotFound      = errors.New("astits: PID not found")
-	ErrPIDAlreadyExists = errors.New("astits: PID already exists")
-	ErrPCRPIDInvalid    = errors.New("astits: PCR PID invalid")
+	ErrPIDNotFound          = errors.New("astits: PID not found")
+	ErrPIDAlreadyExists     = errors.New("astits: PID already exists")
+	ErrPCRPIDInvalid        = errors.New("astits: PCR PID invalid")
+	ErrProgramNotFound      = errors.New("astits: program not found")
+	ErrProgramAlreadyExists = errors.New("astits: program already exists")
+	ErrPMTTooLarge          = errors.New("astits: PMT doesn't fit in a section")
 )
 
 type Muxer struct {
 	ctx        context.Context
 	w          io.Writer
 	bitsWriter *astikit.BitsWriter
-
+	l          astikit.CompleteLogger
+
+	autodetectPCRPID       bool
+	cbr                    bool
+	muxDelay               time.Duration
+	muxRate                int // in bits per second
+	networkPID             uint16
+	originalNetworkID      uint16
 	packetSize             int
+	pcrInterval            time.Duration
 	tablesRetransmitPeriod int // period in PES packets
+	transportStreamID      uint16
+	// Retransmit intervals indexed by table type, tables without interval use the retransmit period
+	tablesRetransmitIntervals map[string]time.Duration
+
+	// Arrival timestamps of 192-byte packets
+	atsBase    int64 // ATS of the last packet carrying a PCR, in 27MHz units
+	atsPackets int64 // number of packets written since the last packet carrying a PCR
+
+	// Position of the stream in constant bitrate mode
+	cbrOrigin    int64 // time of the first packet, in 27MHz units
+	hasCBROrigin bool
+	packets      int64 // number of packets written so far
+
+	// Stream time based on the PES timestamps of every program, whose clocks may be unrelated
+	clocks *streamClocks // program number -> clock
 
 	pm         *programMap // pid -> programNumber
 	pmUpdated  bool
-	pmt        PMTData
-	pmtUpdated bool
 	nextPID    uint16
 	patVersion wrappingCounter
-	pmtVersion wrappingCounter
 	patCC      wrappingCounter
-	pmtCC      wrappingCounter
+	patLast    tableRetransmission
 
 	patBytes bytes.Buffer
-	pmtBytes bytes.Buffer
 
 	buf       bytes.Buffer
 	bufWriter *astikit.BitsWriter
 
 	// We use map[uint32] instead map[uint16] as go runtime provide optimized hash functions for (u)int32/64 keys
 	esContexts              map[uint32]*esContext
+	programs                map[uint32]*muxerProgram // program number -> program
 	tablesRetransmitCounter int
+
+	// Sections written by the muxer besides the PAT and the PMTs
+	sectionsBytes bytes.Buffer
+	sectionsCCs   map[uint32]*wrappingCounter // pid -> continuity counter
+
+	// SI tables
+	siTables        map[uint32]*muxerSITable    // table ID and table ID extension -> table
+	siTableVersions map[uint32]*wrappingCounter // table ID and table ID extension -> version, kept once the table is removed
 }
 
 type esContext struct {
-	es *PMTElementaryStream
-	cc wrappingCounter
+	es      *PMTElementaryStream
+	cc      wrappingCounter
+	program *muxerProgram
 }
 
-func newEsContext(es *PMTElementaryStream) *esContext {
+func newEsContext(es *PMTElementaryStream, program *muxerProgram) *esContext {
 	return &esContext{
-		es: es,
-		cc: newWrappingCounter(0b1111), // CC is 4 bits
+		es:      es,
+		cc:      newWrappingCounter(0b1111), // CC is 4 bits
+		program: program,
+	}
+}
+
+// ccWithoutPayload returns the continuity counter of a packet without payload, which is not incremented
+func (ctx *esContext) ccWithoutPayload() uint8 {
+	// No packet has been written yet
+	if ctx.cc.get() > ctx.cc.wrapAt {
+		ctx.cc.inc()
+	}
+	return uint8(ctx.cc.get())
+}
+
+// muxerProgram represents a program and its PMT
+type muxerProgram struct {
+	pmt        PMTData
+	pmtBytes   bytes.Buffer
+	pmtCC      wrappingCounter
+	pmtPID     uint16
+	pmtLast    tableRetransmission
+	pmtUpdated bool
+	pmtVersion wrappingCounter
+
+	hasPCR  bool
+	lastPCR int64 // in 27MHz units
+}
+
+// tableRetransmission keeps track of the last time a table has been written, in 27MHz units
+type tableRetransmission struct {
+	hasLast bool
+	last    int64
+}
+
+// isDue checks whether a table must be written again at t, which is a monotonic stream time in 27MHz units
+func (r *tableRetransmission) isDue(t int64, interval time.Duration) bool {
+	return !r.hasLast || t-r.last >= int64(interval)*27/1000
+}
+
+func newMuxerProgram(pmtPID uint16, pmt PMTData) *muxerProgram {
+	return &muxerProgram{
+		pmt:        pmt,
+		pmtCC:      newWrappingCounter(0b1111),
+		pmtPID:     pmtPID,
+		pmtUpdated: true,
+		// table version is 5-bit field
+		pmtVersion: newWrappingCounter(0b11111),
 	}
 }
 
@@ -68,74 +160,247 @@ func MuxerOptTablesRetransmitPeriod(newPeriod int) func(*Muxer) {
 	}
 }
 
-// TODO MuxerOptAutodetectPCRPID selecting first video PID for each PMT, falling back to first audio, falling back to any other
+// MuxerOptTablesRetransmitInterval returns the option to write tables of a type, either PSITableTypePAT or
+// PSITableTypePMT, every interval of stream time instead of every tables retransmit period PES packets.
+// Stream time is the position of the packets in constant bitrate mode, and the DTS, or the PTS if there's no DTS, of
+// the PES packets otherwise. TR 101 290 recommends an interval of at most 100ms for PAT and PMT.
+// Tables are still written along PES packets of the PCR PID whose random access indicator is set.
+func MuxerOptTablesRetransmitInterval(tableType string, interval time.Duration) func(*Muxer) {
+	return func(m *Muxer) {
+		m.tablesRetransmitIntervals[tableType] = interval
+	}
+}
+
+// MuxerOptTransportStreamID returns the option to set the transport stream ID written in the PAT, and in the actual
+// SDT and EIT whose TransportStreamID is 0
+func MuxerOptTransportStreamID(id uint16) func(*Muxer) {
+	return func(m *Muxer) {
+		m.transportStreamID = id
+	}
+}
+
+// MuxerOptOriginalNetworkID returns the option to set the original network ID written in the actual SDT and EIT
+// whose OriginalNetworkID is 0
+func MuxerOptOriginalNetworkID(id uint16) func(*Muxer) {
+	return func(m *Muxer) {
+		m.originalNetworkID = id
+	}
+}
+
+// MuxerOptNetworkPID returns the option to list the PID of the NIT, usually 0x10, in the PAT with program number 0
+func MuxerOptNetworkPID(pid uint16) func(*Muxer) {
+	return func(m *Muxer) {
+		m.networkPID = pid
+	}
+}
+
+// MuxerOptPacketSize returns the option to set the packet size: either 188 (default) or 192.
+// 192-byte packets are preceded by a TP_extra_header whose arrival timestamp is the PCR for packets carrying one,
+// and is derived from the last PCR and the mux rate for the others (see MuxerOptMuxRate).
+func MuxerOptPacketSize(packetSize int) func(*Muxer) {
+	return func(m *Muxer) {
+		m.packetSize = packetSize
+	}
+}
+
+// MuxerOptMuxRate returns the option to set the mux rate in bits per second
+// If it's not set, arrival timestamps of 192-byte packets only change on packets carrying a PCR
+func MuxerOptMuxRate(bitrate int) func(*Muxer) {
+	return func(m *Muxer) {
+		m.muxRate = bitrate
+	}
+}
+
+// MuxerOptConstantBitrate returns the option to write a constant bitrate stream at bitrate bits per second, which
+// also sets the mux rate.
+// Null packets are inserted so that PES packets are written the mux delay before their DTS, or PTS if there's no DTS
+// (see MuxerOptMuxDelay). PCRs are
+// derived from the position of the packets in the stream instead of the PES timestamps, and are inserted every 40ms
+// unless MuxerOptPCRInterval is used, including in the middle of PES packets spanning over several intervals. When the
+// elementary streams exceed the bitrate, PES packets end up being written after their timestamp and a warning is
+// logged.
+func MuxerOptConstantBitrate(bitrate int) func(*Muxer) {
+	return func(m *Muxer) {
+		m.cbr = true
+		m.muxRate = bitrate
+	}
+}
+
+// MuxerOptMuxDelay returns the option to set the delay between the moment PES packets are written and their DTS, or PTS
+// if there's no DTS, which is the time decoders have to buffer them. Default is 500ms.
+// PCRs derived from the PES timestamps are set this long before them, and in constant bitrate mode PES packets are
+// written this long before their timestamp.
+func MuxerOptMuxDelay(delay time.Duration) func(*Muxer) {
+	return func(m *Muxer) {
+		m.muxDelay = delay
+	}
+}
+
+// MuxerOptLogger returns the option to set the logger
+func MuxerOptLogger(l astikit.StdLogger) func(*Muxer) {
+	return func(m *Muxer) {
+		m.l = astikit.AdaptStdLogger(l)
+	}
+}
+
+// MuxerOptAutodetectPCRPID returns the option to select the PCR PID of programs whose PCR PID is not one of their
+// elementary streams: first video PID, falling back to first audio PID, falling back to any other
+func MuxerOptAutodetectPCRPID() func(*Muxer) {
+	return func(m *Muxer) {
+		m.autodetectPCRPID = true
+	}
+}
+
+// MuxerOptPCRInterval returns the option to insert PCRs automatically on the PCR PID of programs, at most every
+// interval. Interval can't be more than 40ms.
+// The PCR is derived from the DTS, or the PTS if there's no DTS, of the PES packets written on the program minus the
+// mux delay (see MuxerOptMuxDelay), and is written in the adaptation field of the PCR PID PES packets. When a PCR is
+// due while writing a PES packet on another PID, a packet carrying only an adaptation field with the PCR is written on
+// the PCR PID.
+// PCRs never go backwards: PES packets whose timestamp is earlier than the last PCR, as happens when elementary
+// streams are interleaved, don't trigger a PCR. Timestamps discontinuities must be signalled with the discontinuity
+// indicator of the MuxerData.AdaptationField of a PCR PID PES packet.
+// PCRs provided in MuxerData.AdaptationField are left untouched.
+func MuxerOptPCRInterval(interval time.Duration) func(*Muxer) {
+	return func(m *Muxer) {
+		m.pcrInterval = interval
+		if m.pcrInterval > maxPCRInterval {
+			m.pcrInterval = maxPCRInterval
+		}
+	}
+}
 
 func NewMuxer(ctx context.Context, w io.Writer, opts ...func(*Muxer)) *Muxer {
 	m := &Muxer{
 		ctx: ctx,
+		l:   astikit.AdaptStdLogger(nil),
 		w:   w,
 
-		packetSize:             MpegTsPacketSize, // no 192-byte packet support yet
-		tablesRetransmitPeriod: 40,
+		muxDelay:                  defaultMuxDelay,
+		packetSize:                MpegTsPacketSize,
+		tablesRetransmitIntervals: map[string]time.Duration{},
+		tablesRetransmitPeriod:    40,
 
-		pm: newProgramMap(),
-		pmt: PMTData{
-			ElementaryStreams: []*PMTElementaryStream{},
-			ProgramNumber:     programNumberStart,
-		},
+		clocks:  newStreamClocks(),
+		pm:      newProgramMap(),
+		nextPID: startPID,
 
 		// table version is 5-bit field
 		patVersion: newWrappingCounter(0b11111),
-		pmtVersion: newWrappingCounter(0b11111),
 
 		patCC: newWrappingCounter(0b1111),
-		pmtCC: newWrappingCounter(0b1111),
 
 		esContexts: map[uint32]*esContext{},
+		programs:   map[uint32]*muxerProgram{},
+
+		sectionsCCs:     map[uint32]*wrappingCounter{},
+		siTables:        map[uint32]*muxerSITable{},
+		siTableVersions: map[uint32]*wrappingCounter{},
 	}
 
 	m.bufWriter = astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.buf})
 	m.bitsWriter = astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: m.w})
 
-	// TODO multiple programs support
-	m.pm.setUnlocked(pmtStartPID, programNumberStart)
-	m.pmUpdated = true
-
 	for _, opt := range opts {
 		opt(m)
 	}
 
+	// Default program, used by AddElementaryStream, RemoveElementaryStream and SetPCRPID
+	// It's added once options have been applied so that its PMT PID doesn't collide with the PIDs they set
+	pmtPID := pmtStartPID
+	for m.isPIDUsed(pmtPID) {
+		pmtPID++
+	}
+	m.AddProgram(pmtPID, PMTData{ProgramNumber: programNumberStart})
+
+	// PCRs are mandatory in constant bitrate mode
+	if m.cbr && m.pcrInterval <= 0 {
+		m.pcrInterval = maxPCRInterval
+	}
+
 	// to output tables at the very start
 	m.tablesRetransmitCounter = m.tablesRetransmitPeriod
 
 	return m
 }
 
+// AddProgram adds a program whose PMT is written on pmtPID
+// A default program whose program number is 1 and whose PMT PID is 0x1000 is created by NewMuxer: remove it if you
+// don't need it.
+// if the ElementaryPID of an elementary stream is zero, it will be generated automatically
+func (m *Muxer) AddProgram(pmtPID uint16, pmt PMTData) error {
+	if _, ok := m.programs[uint32(pmt.ProgramNumber)]; ok {
+		return ErrProgramAlreadyExists
+	}
+	if m.isPIDUsed(pmtPID) {
+		return ErrPIDAlreadyExists
+	}
+
+	p := newMuxerProgram(pmtPID, pmt)
+	p.pmt.ElementaryStreams = []*PMTElementaryStream{}
+	m.programs[uint32(pmt.ProgramNumber)] = p
+	m.pm.setUnlocked(pmtPID, pmt.ProgramNumber)
+	m.pmUpdated = true
+
+	for _, es := range pmt.ElementaryStreams {
+		if err := m.AddProgramElementaryStream(pmt.ProgramNumber, *es); err != nil {
+			m.RemoveProgram(pmt.ProgramNumber)
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveProgram removes a program and its elementary streams
+func (m *Muxer) RemoveProgram(programNumber uint16) error {
+	p, ok := m.programs[uint32(programNumber)]
+	if !ok {
+		return ErrProgramNotFound
+	}
+
+	for _, es := range p.pmt.ElementaryStreams {
+		delete(m.esContexts, uint32(es.ElementaryPID))
+	}
+	delete(m.programs, uint32(programNumber))
+	m.pm.unsetUnlocked(p.pmtPID)
+	m.pmUpdated = true
+	return nil
+}
+
+// AddProgramElementaryStream adds an elementary stream to a program
 // if es.ElementaryPID is zero, it will be generated automatically
-func (m *Muxer) AddElementaryStream(es PMTElementaryStream) error {
+func (m *Muxer) AddProgramElementaryStream(programNumber uint16, es PMTElementaryStream) error {
+	p, ok := m.programs[uint32(programNumber)]
+	if !ok {
+		return ErrProgramNotFound
+	}
+
 	if es.ElementaryPID != 0 {
-		for _, oes := range m.pmt.ElementaryStreams {
-			if oes.ElementaryPID == es.ElementaryPID {
-				return ErrPIDAlreadyExists
-			}
+		if m.isPIDUsed(es.ElementaryPID) {
+			return ErrPIDAlreadyExists
 		}
 	} else {
-		es.ElementaryPID = m.nextPID
-		m.nextPID++
+		es.ElementaryPID = m.generatePID()
 	}
 
-	m.pmt.ElementaryStreams = append(m.pmt.ElementaryStreams, &es)
+	p.pmt.ElementaryStreams = append(p.pmt.ElementaryStreams, &es)
 
-	m.esContexts[uint32(es.ElementaryPID)] = newEsContext(&es)
+	m.esContexts[uint32(es.ElementaryPID)] = newEsContext(&es, p)
 	// invalidate pmt cache
-	m.pmtBytes.Reset()
-	m.pmtUpdated = true
+	p.pmtBytes.Reset()
+	p.pmtUpdated = true
 	return nil
 }
 
-func (m *Muxer) RemoveElementaryStream(pid uint16) error {
+// RemoveProgramElementaryStream removes an elementary stream from a program
+func (m *Muxer) RemoveProgramElementaryStream(programNumber, pid uint16) error {
+	p, ok := m.programs[uint32(programNumber)]
+	if !ok {
+		return ErrProgramNotFound
+	}
+
 	foundIdx := -1
-	for i, oes := range m.pmt.ElementaryStreams {
+	for i, oes := range p.pmt.ElementaryStreams {
 		if oes.ElementaryPID == pid {
 			foundIdx = i
 			break
@@ -146,23 +411,362 @@ func (m *Muxer) RemoveElementaryStream(pid uint16) error {
 		return ErrPIDNotFound
 	}
 
-	m.pmt.ElementaryStreams = append(m.pmt.ElementaryStreams[:foundIdx], m.pmt.ElementaryStreams[foundIdx+1:]...)
+	p.pmt.ElementaryStreams = append(p.pmt.ElementaryStreams[:foundIdx], p.pmt.ElementaryStreams[foundIdx+1:]...)
 	delete(m.esContexts, uint32(pid))
-	m.pmtBytes.Reset()
-	m.pmtUpdated = true
+	p.pmtBytes.Reset()
+	p.pmtUpdated = true
+	return nil
+}
+
+// SetProgramPMTPID changes the PID the PMT of a program is written on
+func (m *Muxer) SetProgramPMTPID(programNumber, pid uint16) error {
+	p, ok := m.programs[uint32(programNumber)]
+	if !ok {
+		return ErrProgramNotFound
+	}
+	if pid == p.pmtPID {
+		return nil
+	}
+	if m.isPIDUsed(pid) {
+		return ErrPIDAlreadyExists
+	}
+
+	m.pm.unsetUnlocked(p.pmtPID)
+	m.pm.setUnlocked(pid, programNumber)
+	m.pmUpdated = true
+	p.pmtCC = newWrappingCounter(0b1111)
+	p.pmtPID = pid
 	return nil
 }
 
-// SetPCRPID marks pid as one to look PCRs in
+// SetProgramPCRPID marks pid as one to look PCRs in for a program
+func (m *Muxer) SetProgramPCRPID(programNumber, pid uint16) error {
+	p, ok := m.programs[uint32(programNumber)]
+	if !ok {
+		return ErrProgramNotFound
+	}
+
+	p.pmt.PCRPID = pid
+	p.pmtUpdated = true
+	return nil
+}
+
+// SetProgramDescriptors sets the program descriptors of a program
+func (m *Muxer) SetProgramDescriptors(programNumber uint16, ds []*Descriptor) error {
+	p, ok := m.programs[uint32(programNumber)]
+	if !ok {
+		return ErrProgramNotFound
+	}
+
+	p.pmt.ProgramDescriptors = ds
+	p.pmtUpdated = true
+	return nil
+}
+
+// if es.ElementaryPID is zero, it will be generated automatically
+// The elementary stream is added to the default program
+func (m *Muxer) AddElementaryStream(es PMTElementaryStream) error {
+	return m.AddProgramElementaryStream(programNumberStart, es)
+}
+
+// RemoveElementaryStream removes an elementary stream from the default program
+func (m *Muxer) RemoveElementaryStream(pid uint16) error {
+	if err := m.RemoveProgramElementaryStream(programNumberStart, pid); err == ErrProgramNotFound {
+		return ErrPIDNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetPCRPID marks pid as one to look PCRs in for the default program
 func (m *Muxer) SetPCRPID(pid uint16) {
-	m.pmt.PCRPID = pid
-	m.pmtUpdated = true
+	m.SetProgramPCRPID(programNumberStart, pid)
+}
+
+// detectPCRPID selects the PCR PID of a program if needed
+func (m *Muxer) detectPCRPID(p *muxerProgram) {
+	if !m.autodetectPCRPID || len(p.pmt.ElementaryStreams) == 0 {
+		return
+	}
+
+	// PCR PID is valid
+	var video, audio *PMTElementaryStream
+	for _, es := range p.pmt.ElementaryStreams {
+		if es.ElementaryPID == p.pmt.PCRPID {
+			return
+		}
+		if video == nil && es.StreamType.IsVideo() {
+			video = es
+		} else if audio == nil && es.StreamType.IsAudio() {
+			audio = es
+		}
+	}
+
+	// Select PCR PID
+	es := p.pmt.ElementaryStreams[0]
+	if video != nil {
+		es = video
+	} else if audio != nil {
+		es = audio
+	}
+	p.pmt.PCRPID = es.ElementaryPID
+	p.pmtUpdated = true
+}
+
+// insertPCR inserts a PCR in d or writes a packet carrying only a PCR if a PCR is due for the program
+func (m *Muxer) insertPCR(d *MuxerData, p *muxerProgram) (int, error) {
+	// PCR is provided
+	if d.PID == p.pmt.PCRPID && d.AdaptationField != nil && d.AdaptationField.HasPCR && d.AdaptationField.PCR != nil {
+		// In constant bitrate mode, PCR must match the position of the packet
+		if m.cbr && m.hasCBROrigin {
+			d.AdaptationField.PCR = newPCR(m.positionTime())
+		}
+		p.hasPCR = true
+		p.lastPCR = d.AdaptationField.PCR.Base*300 + d.AdaptationField.PCR.Extension
+		return 0, nil
+	}
+
+	// Get PCR
+	if m.pcrInterval <= 0 {
+		return 0, nil
+	}
+	if d.PID == p.pmt.PCRPID && d.AdaptationField != nil && d.AdaptationField.DiscontinuityIndicator {
+		// PCR starts over
+		p.hasPCR = false
+	}
+	var pcr int64
+	if m.cbr {
+		// PCR is derived from the position of the next packet
+		if !m.hasCBROrigin {
+			return 0, nil
+		}
+		pcr = m.positionTime()
+	} else {
+		ts := pesTimestamp(d.PES)
+		if ts == nil {
+			return 0, nil
+		}
+		// Decoders need time to buffer the PES packet before its DTS
+		pcr = ts.Base*300 - m.muxDelayTime()
+	}
+
+	// PCR is not due
+	if !m.isPCRDue(p, pcr) {
+		return 0, nil
+	}
+
+	// PCR is written in the PES packet adaptation field
+	if d.PID == p.pmt.PCRPID {
+		if d.AdaptationField == nil {
+			d.AdaptationField = &PacketAdaptationField{}
+		}
+		d.AdaptationField.HasPCR = true
+		d.AdaptationField.PCR = newPCR(pcr)
+		p.hasPCR = true
+		p.lastPCR = pcr
+		return 0, nil
+	}
+	return m.writePCRPacket(p, pcr)
+}
+
+// insertPositionPCRs writes packets carrying only a PCR for the programs whose PCR is due at the position of the next
+// packet in constant bitrate mode, so that the PCR interval is enforced while writing PES packets spanning over
+// several intervals. If the PCR of the program whose PCR PID is pid is due, it's returned in an adaptation field
+// instead, to be written in the next packet.
+func (m *Muxer) insertPositionPCRs(pid uint16) (af *PacketAdaptationField, n int, err error) {
+	if !m.cbr || !m.hasCBROrigin || m.pcrInterval <= 0 {
+		return
+	}
+
+	// Loop through programs
+	var pidProgram *muxerProgram
+	for _, p := range m.sortedPrograms() {
+		if _, ok := m.esContexts[uint32(p.pmt.PCRPID)]; !ok {
+			continue
+		}
+
+		// The PCR of the PID must match the position of the next packet, therefore it's handled last
+		if p.pmt.PCRPID == pid {
+			pidProgram = p
+			continue
+		}
+
+		// Write PCR packet
+		if pos := m.positionTime(); m.isPCRDue(p, pos) {
+			var pn int
+			if pn, err = m.writePCRPacket(p, pos); err != nil {
+				return
+			}
+			n += pn
+		}
+	}
+
+	// PCR is written in the adaptation field of the next packet
+	if pos := m.positionTime(); pidProgram != nil && m.isPCRDue(pidProgram, pos) {
+		pidProgram.hasPCR = true
+		pidProgram.lastPCR = pos
+		af = &PacketAdaptationField{HasPCR: true, PCR: newPCR(pos)}
+	}
+	return
+}
+
+// isPCRDue checks whether a PCR must be written for the program at pcr, in 27MHz units
+// pcr must be at least the PCR interval ahead of the last PCR, taking the 33-bit wrap into account, so that PCRs
+// never go backwards
+func (m *Muxer) isPCRDue(p *muxerProgram, pcr int64) bool {
+	if !p.hasPCR {
+		return true
+	}
+	d := ((pcr-p.lastPCR)%clockWrap + clockWrap) % clockWrap
+	return d < clockWrap/2 && d >= int64(m.pcrInterval)*27/1000
+}
+
+// writePCRPacket writes a packet carrying only a PCR on the PCR PID of the program
+func (m *Muxer) writePCRPacket(p *muxerProgram, pcr int64) (int, error) {
+	// PCR PID is unknown
+	ctx, ok := m.esContexts[uint32(p.pmt.PCRPID)]
+	if !ok {
+		return 0, nil
+	}
+	p.hasPCR = true
+	p.lastPCR = pcr
+
+	return m.writePacket(&Packet{
+		AdaptationField: &PacketAdaptationField{
+			HasPCR: true,
+			PCR:    newPCR(pcr),
+			// one byte for length and one for flags
+			StuffingLength: MpegTsPacketSize - 1 - mpegTsPacketHeaderSize - 2 - pcrBytesSize,
+		},
+		Header: PacketHeader{
+			ContinuityCounter:  ctx.ccWithoutPayload(),
+			HasAdaptationField: true,
+			PID:                p.pmt.PCRPID,
+		},
+	})
+}
+
+// muxDelayTime returns the mux delay in 27MHz units
+func (m *Muxer) muxDelayTime() int64 {
+	return int64(m.muxDelay) * 27 / 1000
+}
+
+// newPCR creates a clock reference out of a PCR in 27MHz units, which wraps around if it's out of range
+func newPCR(pcr int64) *ClockReference {
+	pcr = (pcr%clockWrap + clockWrap) % clockWrap
+	return newClockReference(pcr/300, pcr%300)
+}
+
+// pesTimestamp returns the DTS of a PES packet, or its PTS if there's no DTS
+func pesTimestamp(d *PESData) *ClockReference {
+	if d.Header == nil || d.Header.OptionalHeader == nil {
+		return nil
+	}
+	switch d.Header.OptionalHeader.PTSDTSIndicator {
+	case PTSDTSIndicatorOnlyPTS:
+		return d.Header.OptionalHeader.PTS
+	case PTSDTSIndicatorBothPresent:
+		return d.Header.OptionalHeader.DTS
+	}
+	return nil
+}
+
+// positionTime returns the time of the next packet in constant bitrate mode, in 27MHz units
+func (m *Muxer) positionTime() int64 {
+	return m.cbrOrigin + m.packetsDuration(m.packets)
+}
+
+// packetsDuration returns the duration of n packets at the mux rate, in 27MHz units
+func (m *Muxer) packetsDuration(n int64) int64 {
+	// Use 128-bit arithmetic since the number of packets multiplied by the duration of a packet quickly overflows
+	hi, lo := bits.Mul64(uint64(n), MpegTsPacketSize*8*27000000)
+	q, _ := bits.Div64(hi, lo, uint64(m.muxRate))
+	return int64(q)
+}
+
+// stuff writes null packets, or packets carrying only a PCR when one is due, until the PES packet of d is due in
+// constant bitrate mode
+func (m *Muxer) stuff(d *MuxerData) (int, error) {
+	if !m.cbr {
+		return 0, nil
+	}
+	ts := pesTimestamp(d.PES)
+	if ts == nil {
+		return 0, nil
+	}
+
+	// The first PES packet with a timestamp sets the origin of the stream
+	due := ts.Base*300 - m.muxDelayTime()
+	if !m.hasCBROrigin {
+		m.hasCBROrigin = true
+		m.cbrOrigin = due - m.packetsDuration(m.packets)
+	}
+
+	// PES packet is late
+	if late := m.positionTime() - ts.Base*300; late > 0 {
+		m.l.Warnf("astits: mux rate of %d bps exceeded: PES packet on PID %d is written %s after its timestamp", m.muxRate, d.PID, time.Duration(late*1000/27))
+		return 0, nil
+	}
+
+	bytesWritten := 0
+	ps := m.sortedPrograms()
+	for pos := m.positionTime(); pos < due; pos = m.positionTime() {
+		// Get program whose PCR is due
+		var pcrProgram *muxerProgram
+		for _, p := range ps {
+			if _, ok := m.esContexts[uint32(p.pmt.PCRPID)]; ok && m.isPCRDue(p, pos) {
+				pcrProgram = p
+				break
+			}
+		}
+
+		// Write packet
+		var n int
+		var err error
+		if pcrProgram != nil {
+			n, err = m.writePCRPacket(pcrProgram, pos)
+		} else {
+			n, err = m.writePacket(&Packet{Header: PacketHeader{HasPayload: true, PID: PIDNull}})
+		}
+		if err != nil {
+			return bytesWritten, err
+		}
+		bytesWritten += n
+	}
+	return bytesWritten, nil
+}
+
+// isPIDUsed checks whether pid is already used by a table or an elementary stream
+func (m *Muxer) isPIDUsed(pid uint16) bool {
+	if pid == PIDPAT || (m.networkPID > 0 && pid == m.networkPID) || m.pm.existsUnlocked(pid) {
+		return true
+	}
+	_, ok := m.esContexts[uint32(pid)]
+	return ok
+}
+
+// generatePID returns the next unused PID
+func (m *Muxer) generatePID() uint16 {
+	for m.isPIDUsed(m.nextPID) {
+		m.nextPID++
+	}
+	pid := m.nextPID
+	m.nextPID++
+	return pid
 }
 
 // WriteData writes MuxerData to TS stream
-// Currently only PES packets are supported
+// When d carries sections instead of a PES packet, they're written right away on d.PID, which can be any PID but the
+// ones of the PAT and the PMTs: PSI sections must belong to a table the muxer can write, and private sections are
+// written as is. Sections of the same PID share its continuity counter.
 // Be aware that after successful call WriteData will set d.AdaptationField.StuffingLength value to zero
+// When PCRs are inserted automatically, d.AdaptationField may be created and its PCR set
 func (m *Muxer) WriteData(d *MuxerData) (int, error) {
+	if d.PES == nil {
+		return m.writeSections(d)
+	}
+
 	ctx, ok := m.esContexts[uint32(d.PID)]
 	if !ok {
 		return 0, ErrPIDNotFound
@@ -170,13 +774,38 @@ func (m *Muxer) WriteData(d *MuxerData) (int, error) {
 
 	bytesWritten := 0
 
+	m.detectPCRPID(ctx.program)
+
+	n, err := m.stuff(d)
+	if err != nil {
+		return n, err
+	}
+
+	bytesWritten += n
+
 	forceTables := d.AdaptationField != nil &&
 		d.AdaptationField.RandomAccessIndicator &&
-		d.PID == m.pmt.PCRPID
+		d.PID == ctx.program.pmt.PCRPID
 
-	n, err := m.retransmitTables(forceTables)
+	t := m.streamTime(d, ctx.program)
+
+	n, err = m.retransmitTables(forceTables, t)
 	if err != nil {
-		return n, err
+		return bytesWritten, err
+	}
+
+	bytesWritten += n
+
+	n, err = m.retransmitSITables(t)
+	if err != nil {
+		return bytesWritten, err
+	}
+
+	bytesWritten += n
+
+	n, err = m.insertPCR(d, ctx.program)
+	if err != nil {
+		return bytesWritten, err
 	}
 
 	bytesWritten += n
@@ -185,6 +814,15 @@ func (m *Muxer) WriteData(d *MuxerData) (int, error) {
 	writeAf := d.AdaptationField != nil
 	payloadBytesWritten := 0
 	for payloadBytesWritten < len(d.PES.Data) {
+		// Insert PCRs that are due while writing the PES packet
+		var pcrAf *PacketAdaptationField
+		if !writeAf {
+			if pcrAf, n, err = m.insertPositionPCRs(d.PID); err != nil {
+				return bytesWritten, err
+			}
+			bytesWritten += n
+		}
+
 		pktLen := 1 + mpegTsPacketHeaderSize // sync byte + header
 		pkt := Packet{
 			Header: PacketHeader{
@@ -201,9 +839,14 @@ func (m *Muxer) WriteData(d *MuxerData) (int, error) {
 			// one byte for adaptation field length field
 			pktLen += 1 + int(calcPacketAdaptationFieldLength(d.AdaptationField))
 			writeAf = false
+		} else if pcrAf != nil {
+			pkt.AdaptationField = pcrAf
+			pkt.Header.HasAdaptationField = true
+			// one byte for adaptation field length field
+			pktLen += 1 + int(calcPacketAdaptationFieldLength(pcrAf))
 		}
 
-		bytesAvailable := m.packetSize - pktLen
+		bytesAvailable := MpegTsPacketSize - pktLen
 		if payloadStart {
 			pesHeaderLengthCurrent := pesHeaderLength + int(calcPESOptionalHeaderLength(d.PES.Header.OptionalHeader))
 			// d.AdaptationField with pes header are too big, we don't have space to write pes header
@@ -255,7 +898,7 @@ func (m *Muxer) WriteData(d *MuxerData) (int, error) {
 				}
 			}
 
-			n, err = writePacket(m.bitsWriter, &pkt, m.packetSize)
+			n, err = m.writePacket(&pkt)
 			if err != nil {
 				return bytesWritten, err
 			}
@@ -273,103 +916,246 @@ func (m *Muxer) WriteData(d *MuxerData) (int, error) {
 	return bytesWritten, nil
 }
 
+// writeSections writes the sections of d
+func (m *Muxer) writeSections(d *MuxerData) (int, error) {
+	// PAT and PMTs are written by the muxer
+	if d.PID == PIDPAT || m.pm.existsUnlocked(d.PID) {
+		return 0, ErrPIDAlreadyExists
+	}
+
+	// Get continuity counter, which is the elementary stream one if the PID is listed in a PMT
+	var cc *wrappingCounter
+	if ctx, ok := m.esContexts[uint32(d.PID)]; ok {
+		cc = &ctx.cc
+	} else {
+		cc = m.sectionsCC(d.PID)
+	}
+
+	// Write sections
+	m.sectionsBytes.Reset()
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.sectionsBytes})
+	if _, err := writePSISectionsPackets(w, d.PID, cc, d.Sections); err != nil {
+		return 0, err
+	}
+	if _, err := writePrivateSectionsPackets(w, d.PID, cc, d.PrivateSections); err != nil {
+		return 0, err
+	}
+	return m.writeRawPackets([][]byte{m.sectionsBytes.Bytes()})
+}
+
+// sectionsCC returns the continuity counter of sections written on pid
+func (m *Muxer) sectionsCC(pid uint16) *wrappingCounter {
+	cc, ok := m.sectionsCCs[uint32(pid)]
+	if !ok {
+		c := newWrappingCounter(0b1111) // CC is 4 bits
+		cc = &c
+		m.sectionsCCs[uint32(pid)] = cc
+	}
+	return cc
+}
+
 // Writes given packet to MPEG-TS stream
 // Stuffs with 0xffs if packet turns out to be shorter than target packet length
+// When writing 192-byte packets, p.TPExtraHeader is used if set, otherwise it's generated
 func (m *Muxer) WritePacket(p *Packet) (int, error) {
-	return writePacket(m.bitsWriter, p, m.packetSize)
+	return m.writePacket(p)
 }
 
-func (m *Muxer) retransmitTables(force bool) (int, error) {
-	m.tablesRetransmitCounter++
-	if !force && m.tablesRetransmitCounter < m.tablesRetransmitPeriod {
-		return 0, nil
+func (m *Muxer) writePacket(p *Packet) (int, error) {
+	written := 0
+	if m.packetSize == m2tsPacketSize {
+		h := p.TPExtraHeader
+		if h == nil {
+			var pcr *ClockReference
+			if p.Header.HasAdaptationField && p.AdaptationField != nil && p.AdaptationField.HasPCR {
+				pcr = p.AdaptationField.PCR
+			}
+			h = m.nextTPExtraHeader(pcr)
+		}
+		n, err := writePacketTPExtraHeader(m.bitsWriter, h)
+		if err != nil {
+			return written, err
+		}
+		written += n
 	}
 
-	n, err := m.WriteTables()
+	n, err := writePacket(m.bitsWriter, p, MpegTsPacketSize)
 	if err != nil {
-		return n, err
+		return written, err
+	}
+	written += n
+	m.packets++
+	return written, nil
+}
+
+// writeRawPacket writes 188 bytes of an already serialized packet
+func (m *Muxer) writeRawPacket(b []byte) (int, error) {
+	written := 0
+	if m.packetSize == m2tsPacketSize {
+		n, err := writePacketTPExtraHeader(m.bitsWriter, m.nextTPExtraHeader(nil))
+		if err != nil {
+			return written, err
+		}
+		written += n
 	}
 
-	m.tablesRetransmitCounter = 0
-	return n, nil
+	if err := m.bitsWriter.Write(b); err != nil {
+		return written, err
+	}
+	written += len(b)
+	m.packets++
+	return written, nil
 }
 
-func (m *Muxer) WriteTables() (int, error) {
-	bytesWritten := 0
+// nextTPExtraHeader generates the TP_extra_header of the next packet
+// pcr is the PCR carried by the packet, if any
+func (m *Muxer) nextTPExtraHeader(pcr *ClockReference) *PacketTPExtraHeader {
+	if pcr != nil {
+		m.atsBase = pcr.Base*300 + pcr.Extension
+		m.atsPackets = 0
+	}
+	ats := m.atsBase
+	if m.muxRate > 0 {
+		ats += m.packetsDuration(m.atsPackets)
+	}
+	m.atsPackets++
+	return &PacketTPExtraHeader{ArrivalTimeStamp: uint32(ats) & 0x3fffffff}
+}
 
-	if err := m.generatePAT(); err != nil {
-		return bytesWritten, err
+// streamTime returns the monotonic stream time when d, written on program p, is written, in 27MHz units
+// It's based on the position of the stream in constant bitrate mode, or on the DTS, or the PTS if there's no DTS, of
+// the PES packets of every program otherwise. It doesn't move when the PES packet has no timestamp.
+func (m *Muxer) streamTime(d *MuxerData, p *muxerProgram) int64 {
+	if m.cbr {
+		if !m.hasCBROrigin {
+			return 0
+		}
+		return m.packetsDuration(m.packets)
+	} else if ts := pesTimestamp(d.PES); ts != nil {
+		return m.clocks.update(uint32(p.pmt.ProgramNumber), ts.Base*300)
 	}
+	return m.clocks.time()
+}
 
-	if err := m.generatePMT(); err != nil {
-		return bytesWritten, err
+// retransmitTables writes the tables that are due at t, which is the stream time in 27MHz units
+func (m *Muxer) retransmitTables(force bool, t int64) (int, error) {
+	m.tablesRetransmitCounter++
+	periodElapsed := m.tablesRetransmitCounter >= m.tablesRetransmitPeriod
+
+	// isDue checks whether a table is due
+	isDue := func(tableType string, r *tableRetransmission) bool {
+		if force {
+			return true
+		}
+		interval, ok := m.tablesRetransmitIntervals[tableType]
+		if !ok {
+			return periodElapsed
+		}
+		return interval <= 0 || r.isDue(t, interval)
 	}
 
-	n, err := m.w.Write(m.patBytes.Bytes())
-	if err != nil {
-		return bytesWritten, err
+	// Generate tables that are due
+	var bs [][]byte
+	if isDue(PSITableTypePAT, &m.patLast) {
+		if err := m.generatePAT(); err != nil {
+			return 0, err
+		}
+		bs = append(bs, m.patBytes.Bytes())
+		m.patLast = tableRetransmission{hasLast: true, last: t}
+	}
+	for _, p := range m.sortedPrograms() {
+		if !isDue(PSITableTypePMT, &p.pmtLast) {
+			continue
+		}
+		if err := m.generatePMT(p); err != nil {
+			return 0, err
+		}
+		bs = append(bs, p.pmtBytes.Bytes())
+		p.pmtLast = tableRetransmission{hasLast: true, last: t}
 	}
-	bytesWritten += n
 
-	n, err = m.w.Write(m.pmtBytes.Bytes())
-	if err != nil {
-		return bytesWritten, err
+	if force || periodElapsed {
+		m.tablesRetransmitCounter = 0
 	}
-	bytesWritten += n
+	return m.writeRawPackets(bs)
+}
 
+func (m *Muxer) WriteTables() (int, error) {
+	if err := m.generatePAT(); err != nil {
+		return 0, err
+	}
+
+	bs := [][]byte{m.patBytes.Bytes()}
+	for _, p := range m.sortedPrograms() {
+		if err := m.generatePMT(p); err != nil {
+			return 0, err
+		}
+		bs = append(bs, p.pmtBytes.Bytes())
+	}
+
+	return m.writeRawPackets(bs)
+}
+
+// writeRawPackets writes tables already serialized in 188-byte packets
+func (m *Muxer) writeRawPackets(bs [][]byte) (int, error) {
+	bytesWritten := 0
+	for _, b := range bs {
+		for ; len(b) >= MpegTsPacketSize; b = b[MpegTsPacketSize:] {
+			n, err := m.writeRawPacket(b[:MpegTsPacketSize])
+			if err != nil {
+				return bytesWritten, err
+			}
+			bytesWritten += n
+		}
+	}
 	return bytesWritten, nil
 }
 
 func (m *Muxer) generatePAT() error {
-	d := m.pm.toPATDataUnlocked()
+	d := m.pm.toPATDataUnlocked(m.transportStreamID)
+
+	// Program number 0 is reserved to the NIT
+	if m.networkPID > 0 {
+		d.Programs = append([]*PATProgram{{ProgramMapID: m.networkPID}}, d.Programs...)
+	}
 
 	versionNumber := m.patVersion.get()
 	if m.pmUpdated {
 		versionNumber = m.patVersion.inc()
 	}
 
-	syntax := &PSISectionSyntax{
-		Data: &PSISectionSyntaxData{PAT: d},
-		Header: &PSISectionSyntaxHeader{
-			CurrentNextIndicator: true,
-			// TODO support for PAT tables longer than 1 TS packet
-			//LastSectionNumber:    0,
-			//SectionNumber:        0,
-			TableIDExtension: d.TransportStreamID,
-			VersionNumber:    uint8(versionNumber),
-		},
-	}
-	section := PSISection{
-		Header: &PSISectionHeader{
-			SectionLength:          calcPATSectionLength(d),
-			SectionSyntaxIndicator: true,
-			TableID:                PSITableID(d.TransportStreamID),
-		},
-		Syntax: syntax,
-	}
-	psiData := PSIData{
-		Sections: []*PSISection{&section},
+	// PAT is split over several sections when its programs don't fit in one
+	var sections []*PSISection
+	for i := 0; i == 0 || i*patMaxProgramsPerSection < len(d.Programs); i++ {
+		sd := *d
+		sd.Programs = d.Programs[i*patMaxProgramsPerSection:]
+		if len(sd.Programs) > patMaxProgramsPerSection {
+			sd.Programs = sd.Programs[:patMaxProgramsPerSection]
+		}
+		sections = append(sections, &PSISection{
+			Header: &PSISectionHeader{
+				SectionLength:          calcPATSectionLength(&sd),
+				SectionSyntaxIndicator: true,
+				TableID:                PSITableIDPAT,
+			},
+			Syntax: &PSISectionSyntax{
+				Data: &PSISectionSyntaxData{PAT: &sd},
+				Header: &PSISectionSyntaxHeader{
+					CurrentNextIndicator: true,
+					SectionNumber:        uint8(i),
+					TableIDExtension:     d.TransportStreamID,
+					VersionNumber:        uint8(versionNumber),
+				},
+			},
+		})
 	}
-
-	m.buf.Reset()
-	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.buf})
-	if _, err := writePSIData(w, &psiData); err != nil {
-		return err
+	for _, s := range sections {
+		s.Syntax.Header.LastSectionNumber = uint8(len(sections) - 1)
 	}
 
 	m.patBytes.Reset()
-	wPacket := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.patBytes})
-
-	pkt := Packet{
-		Header: PacketHeader{
-			HasPayload:                true,
-			PayloadUnitStartIndicator: true,
-			PID:                       PIDPAT,
-			ContinuityCounter:         uint8(m.patCC.inc()),
-		},
-		Payload: m.buf.Bytes(),
-	}
-	if _, err := writePacket(wPacket, &pkt, m.packetSize); err != nil {
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.patBytes})
+	if _, err := writePSISectionsPackets(w, PIDPAT, &m.patCC, sections); err != nil {
 		// FIXME save old PAT and rollback to it here maybe?
 		return err
 	}
@@ -379,10 +1165,22 @@ func (m *Muxer) generatePAT() error {
 	return nil
 }
 
-func (m *Muxer) generatePMT() error {
+// sortedPrograms returns the programs sorted by program number
+func (m *Muxer) sortedPrograms() (ps []*muxerProgram) {
+	ps = make([]*muxerProgram, 0, len(m.programs))
+	for _, p := range m.programs {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].pmt.ProgramNumber < ps[j].pmt.ProgramNumber })
+	return
+}
+
+func (m *Muxer) generatePMT(p *muxerProgram) error {
+	m.detectPCRPID(p)
+
 	hasPCRPID := false
-	for _, es := range m.pmt.ElementaryStreams {
-		if es.ElementaryPID == m.pmt.PCRPID {
+	for _, es := range p.pmt.ElementaryStreams {
+		if es.ElementaryPID == p.pmt.PCRPID {
 			hasPCRPID = true
 			break
 		}
@@ -391,58 +1189,118 @@ func (m *Muxer) generatePMT() error {
 		return ErrPCRPIDInvalid
 	}
 
-	versionNumber := m.pmtVersion.get()
-	if m.pmtUpdated {
-		versionNumber = m.pmtVersion.inc()
+	versionNumber := p.pmtVersion.get()
+	if p.pmtUpdated {
+		versionNumber = p.pmtVersion.inc()
 	}
 
-	syntax := &PSISectionSyntax{
-		Data: &PSISectionSyntaxData{PMT: &m.pmt},
-		Header: &PSISectionSyntaxHeader{
-			CurrentNextIndicator: true,
-			// TODO support for PMT tables longer than 1 TS packet
-			//LastSectionNumber:    0,
-			//SectionNumber:        0,
-			TableIDExtension: m.pmt.ProgramNumber,
-			VersionNumber:    uint8(versionNumber),
-		},
-	}
-	section := PSISection{
+	section := &PSISection{
 		Header: &PSISectionHeader{
-			SectionLength:          calcPMTSectionLength(&m.pmt),
+			SectionLength:          calcPMTSectionLength(&p.pmt),
 			SectionSyntaxIndicator: true,
 			TableID:                PSITableIDPMT,
 		},
-		Syntax: syntax,
+		Syntax: &PSISectionSyntax{
+			Data: &PSISectionSyntaxData{PMT: &p.pmt},
+			Header: &PSISectionSyntaxHeader{
+				CurrentNextIndicator: true,
+				TableIDExtension:     p.pmt.ProgramNumber,
+				VersionNumber:        uint8(versionNumber),
+			},
+		},
 	}
-	psiData := PSIData{
-		Sections: []*PSISection{&section},
+
+	// PMT can't be split over several sections
+	if calcPSISectionLength(section) > psiSectionMaxLength {
+		return ErrPMTTooLarge
 	}
 
-	m.buf.Reset()
-	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.buf})
-	if _, err := writePSIData(w, &psiData); err != nil {
+	p.pmtBytes.Reset()
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &p.pmtBytes})
+	if _, err := writePSISectionsPackets(w, p.pmtPID, &p.pmtCC, []*PSISection{section}); err != nil {
+		// FIXME save old PMT and rollback to it here maybe?
 		return err
 	}
 
-	m.pmtBytes.Reset()
-	wPacket := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.pmtBytes})
+	p.pmtUpdated = false
 
-	pkt := Packet{
-		Header: PacketHeader{
-			HasPayload:                true,
-			PayloadUnitStartIndicator: true,
-			PID:                       pmtStartPID, // FIXME multiple programs support
-			ContinuityCounter:         uint8(m.pmtCC.inc()),
-		},
-		Payload: m.buf.Bytes(),
+	return nil
+}
+
+// writePSISectionsPackets writes sections in packets
+// Each section starts in a new packet whose pointer field is 0, and the last packet of each section is stuffed with 0xff
+func writePSISectionsPackets(w *astikit.BitsWriter, pid uint16, cc *wrappingCounter, sections []*PSISection) (int, error) {
+	bytesWritten := 0
+	buf := &bytes.Buffer{}
+	bw := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	for _, s := range sections {
+		// Write section
+		buf.Reset()
+		if err := bw.Write(uint8(0)); err != nil { // Pointer field
+			return bytesWritten, err
+		}
+		if _, err := writePSISection(bw, s); err != nil {
+			return bytesWritten, err
+		}
+
+		// Write packets
+		n, err := writeSectionPackets(w, pid, cc, buf.Bytes())
+		if err != nil {
+			return bytesWritten, err
+		}
+		bytesWritten += n
 	}
-	if _, err := writePacket(wPacket, &pkt, m.packetSize); err != nil {
-		// FIXME save old PMT and rollback to it here maybe?
-		return err
+	return bytesWritten, nil
+}
+
+// writePrivateSectionsPackets writes private sections in packets the same way writePSISectionsPackets does
+func writePrivateSectionsPackets(w *astikit.BitsWriter, pid uint16, cc *wrappingCounter, sections []*PrivateSection) (int, error) {
+	bytesWritten := 0
+	buf := &bytes.Buffer{}
+	bw := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	for _, s := range sections {
+		// Write section
+		buf.Reset()
+		if err := bw.Write(uint8(0)); err != nil { // Pointer field
+			return bytesWritten, err
+		}
+		if _, err := writePrivateSection(bw, s); err != nil {
+			return bytesWritten, err
+		}
+
+		// Write packets
+		n, err := writeSectionPackets(w, pid, cc, buf.Bytes())
+		if err != nil {
+			return bytesWritten, err
+		}
+		bytesWritten += n
 	}
+	return bytesWritten, nil
+}
 
-	m.pmtUpdated = false
+// writeSectionPackets writes a section, preceded by its pointer field, in packets
+func writeSectionPackets(w *astikit.BitsWriter, pid uint16, cc *wrappingCounter, b []byte) (int, error) {
+	bytesWritten := 0
+	for start := true; len(b) > 0; start = false {
+		pkt := Packet{
+			Header: PacketHeader{
+				HasPayload:                true,
+				PayloadUnitStartIndicator: start,
+				PID:                       pid,
+				ContinuityCounter:         uint8(cc.inc()),
+			},
+			Payload: b,
+		}
+		if len(pkt.Payload) > MpegTsPacketSize-1-mpegTsPacketHeaderSize {
+			pkt.Payload = pkt.Payload[:MpegTsPacketSize-1-mpegTsPacketHeaderSize]
+		}
+		b = b[len(pkt.Payload):]
 
-	return nil
+		n, err := writePacket(w, &pkt, MpegTsPacketSize)
+		if err != nil {
+			return bytesWritten, err
+		}
+		bytesWritten += n
+	}
+	return bytesWritten, nil
 }
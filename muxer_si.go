@@ -0,0 +1,314 @@
+package astits
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asticode/go-astikit"
+)
+
+// Default repetition intervals of SI tables, which are the maximum intervals of ETSI TS 101 211 (4.4.1)
+const (
+	siIntervalEITPresentFollowingActual = 2 * time.Second
+	siIntervalEITPresentFollowingOther  = 10 * time.Second
+	siIntervalEITScheduleActual         = 10 * time.Second
+	siIntervalEITScheduleOther          = 30 * time.Second
+	siIntervalNIT                       = 10 * time.Second
+	siIntervalSDTActual                 = 2 * time.Second
+	siIntervalSDTOther                  = 10 * time.Second
+	siIntervalTDT                       = 30 * time.Second
+	siIntervalTOT                       = 30 * time.Second
+)
+
+var ErrSITableNotFound = errors.New("astits: SI table not found")
+
+// muxerSITable represents an SI table written periodically by the muxer
+type muxerSITable struct {
+	interval time.Duration
+	last     tableRetransmission
+	pid      uint16
+	sections func(t int64) ([]*PSISection, error) // t is the stream time in 27MHz units
+}
+
+func siTableKey(tableID PSITableID, tableIDExtension uint16) uint32 {
+	return uint32(tableID)<<16 | uint32(tableIDExtension)
+}
+
+// SetNIT sets the NIT of the actual network, or of another network if actual is false. It is written on the PID listed
+// in the PAT (see MuxerOptNetworkPID), PID 0x10 otherwise, every interval of stream time, 10s if interval is 0. Setting
+// the NIT of a network again increments its version.
+func (m *Muxer) SetNIT(d *NITData, actual bool, interval time.Duration) error {
+	tableID := PSITableIDNITVariant2
+	if actual {
+		tableID = PSITableIDNITVariant1
+	}
+	pid := PIDNIT
+	if m.networkPID > 0 {
+		pid = m.networkPID
+	}
+	return m.setSITable(tableID, d.NetworkID, pid, interval, siIntervalNIT, func(versionNumber uint8) ([]*PSISection, error) {
+		return newNITSections(d, tableID, versionNumber)
+	})
+}
+
+// SetSDT sets the SDT of the actual transport stream, or of another transport stream if actual is false. It is
+// written on PID 0x11 every interval of stream time, 2s for the actual transport stream and 10s for the others if
+// interval is 0. Setting the SDT of a transport stream again increments its version.
+func (m *Muxer) SetSDT(d *SDTData, actual bool, interval time.Duration) error {
+	tableID, defaultInterval := PSITableIDSDTVariant2, siIntervalSDTOther
+	if actual {
+		tableID, defaultInterval = PSITableIDSDTVariant1, siIntervalSDTActual
+
+		// Fill IDs
+		v := *d
+		v.OriginalNetworkID, v.TransportStreamID = m.actualIDs(d.OriginalNetworkID, d.TransportStreamID)
+		d = &v
+	}
+	return m.setSITable(tableID, d.TransportStreamID, PIDSDT, interval, defaultInterval, func(versionNumber uint8) ([]*PSISection, error) {
+		return newSDTSections(d, tableID, versionNumber)
+	})
+}
+
+// SetEITPresentFollowing sets the EIT present/following of a service of the actual transport stream, or of another
+// transport stream if actual is false: the first event is the present event and the second one is the following event.
+// It is written on PID 0x12 every interval of stream time, 2s for the actual transport stream and 10s for the others
+// if interval is 0. Setting the EIT present/following of a service again increments its version.
+func (m *Muxer) SetEITPresentFollowing(d *EITData, actual bool, interval time.Duration) error {
+	tableID, defaultInterval := eitTableIDPresentFollowingOther, siIntervalEITPresentFollowingOther
+	if actual {
+		tableID, defaultInterval = eitTableIDPresentFollowingActual, siIntervalEITPresentFollowingActual
+
+		// Fill IDs
+		v := *d
+		v.OriginalNetworkID, v.TransportStreamID = m.actualIDs(d.OriginalNetworkID, d.TransportStreamID)
+		d = &v
+	}
+	return m.setSITable(tableID, d.ServiceID, PIDEIT, interval, defaultInterval, func(versionNumber uint8) ([]*PSISection, error) {
+		return newEITPresentFollowingSections(d, actual, versionNumber)
+	})
+}
+
+// SetEITSchedule sets the EIT schedule of a service of the actual transport stream, or of another transport stream
+// if actual is false. utc is the UTC time of the first time the schedule is written, and is then advanced by the stream
+// time. Events are laid out over segments starting at midnight UTC of the day of the UTC time, and are laid out again
+// with an incremented version when the day changes. It is written on PID 0x12 every interval of stream time, 10s for the
+// actual transport stream and 30s for the others if interval is 0. Setting the EIT schedule of a service again
+// increments its version.
+// Use the table ID of the first schedule table, 0x50 or 0x60, to remove it.
+func (m *Muxer) SetEITSchedule(d *EITData, actual bool, utc time.Time, interval time.Duration) error {
+	tableID, defaultInterval := eitTableIDScheduleOther, siIntervalEITScheduleOther
+	if actual {
+		tableID, defaultInterval = eitTableIDScheduleActual, siIntervalEITScheduleActual
+
+		// Fill IDs
+		v := *d
+		v.OriginalNetworkID, v.TransportStreamID = m.actualIDs(d.OriginalNetworkID, d.TransportStreamID)
+		d = &v
+	}
+
+	// Lay out events
+	key := siTableKey(tableID, d.ServiceID)
+	s := &muxerEITSchedule{
+		actual:  actual,
+		d:       d,
+		utc:     newMuxerUTCTime(utc),
+		version: m.siTableVersion(key),
+	}
+	if err := s.layOut(utc); err != nil {
+		return err
+	}
+
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	m.siTables[key] = &muxerSITable{
+		interval: interval,
+		pid:      PIDEIT,
+		sections: s.sections,
+	}
+	return nil
+}
+
+// SetTDT sets the TDT, written on PID 0x14 every interval of stream time, 30s if interval is 0.
+// d.UTCTime is the UTC time of the first time the TDT is written, and is then advanced by the stream time.
+func (m *Muxer) SetTDT(d *TDTData, interval time.Duration) error {
+	utc := newMuxerUTCTime(d.UTCTime)
+	return m.setTimeSITable(PSITableIDTDT, interval, siIntervalTDT, func(t int64) *PSISection {
+		return newTDTSection(&TDTData{UTCTime: utc.at(t)})
+	})
+}
+
+// SetTOT sets the TOT, written on PID 0x14 every interval of stream time, 30s if interval is 0.
+// d.UTCTime is the UTC time of the first time the TOT is written, and is then advanced by the stream time.
+func (m *Muxer) SetTOT(d *TOTData, interval time.Duration) error {
+	utc := newMuxerUTCTime(d.UTCTime)
+	return m.setTimeSITable(PSITableIDTOT, interval, siIntervalTOT, func(t int64) *PSISection {
+		return newTOTSection(&TOTData{Descriptors: d.Descriptors, UTCTime: utc.at(t)})
+	})
+}
+
+// RemoveSITable stops writing an SI table
+// Its version is kept so that setting it again increments it.
+// tableIDExtension is the network ID for the NIT, the transport stream ID for the SDT, the service ID for the EIT and
+// 0 for the TDT and the TOT
+func (m *Muxer) RemoveSITable(tableID PSITableID, tableIDExtension uint16) error {
+	key := siTableKey(tableID, tableIDExtension)
+	if _, ok := m.siTables[key]; !ok {
+		return ErrSITableNotFound
+	}
+	delete(m.siTables, key)
+	return nil
+}
+
+// actualIDs returns the original network ID and transport stream ID of tables describing the actual transport stream,
+// using the muxer ones if they're not set
+func (m *Muxer) actualIDs(originalNetworkID, transportStreamID uint16) (uint16, uint16) {
+	if originalNetworkID == 0 {
+		originalNetworkID = m.originalNetworkID
+	}
+	if transportStreamID == 0 {
+		transportStreamID = m.transportStreamID
+	}
+	return originalNetworkID, transportStreamID
+}
+
+func (m *Muxer) setSITable(tableID PSITableID, tableIDExtension, pid uint16, interval, defaultInterval time.Duration, sections func(versionNumber uint8) ([]*PSISection, error)) error {
+	// Increment version of the table, even if it has been removed
+	key := siTableKey(tableID, tableIDExtension)
+	version := m.siTableVersion(key)
+	next := *version
+	ss, err := sections(uint8(next.inc()))
+	if err != nil {
+		return err
+	}
+	*version = next
+
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	m.siTables[key] = &muxerSITable{
+		interval: interval,
+		pid:      pid,
+		sections: func(int64) ([]*PSISection, error) { return ss, nil },
+	}
+	return nil
+}
+
+// siTableVersion returns the version of the SI table with the given key
+func (m *Muxer) siTableVersion(key uint32) *wrappingCounter {
+	v, ok := m.siTableVersions[key]
+	if !ok {
+		c := newWrappingCounter(0b11111) // table version is 5-bit field
+		v = &c
+		m.siTableVersions[key] = v
+	}
+	return v
+}
+
+func (m *Muxer) setTimeSITable(tableID PSITableID, interval, defaultInterval time.Duration, section func(t int64) *PSISection) error {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	m.siTables[siTableKey(tableID, 0)] = &muxerSITable{
+		interval: interval,
+		pid:      PIDTDT,
+		sections: func(t int64) ([]*PSISection, error) { return []*PSISection{section(t)}, nil },
+	}
+	return nil
+}
+
+// retransmitSITables writes the SI tables that are due at t, which is the monotonic stream time in 27MHz units
+func (m *Muxer) retransmitSITables(t int64) (int, error) {
+	// Get tables that are due
+	var keys []uint32
+	for k, st := range m.siTables {
+		if st.last.isDue(t, st.interval) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	bytesWritten := 0
+	for _, k := range keys {
+		st := m.siTables[k]
+
+		// Get sections
+		ss, err := st.sections(t)
+		if err != nil {
+			return bytesWritten, fmt.Errorf("astits: getting sections of SI table %#x failed: %w", k, err)
+		}
+
+		// Write sections, whose continuity counter is shared by the tables of the PID
+		m.sectionsBytes.Reset()
+		w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: &m.sectionsBytes})
+		if _, err := writePSISectionsPackets(w, st.pid, m.sectionsCC(st.pid), ss); err != nil {
+			return bytesWritten, err
+		}
+		n, err := m.writeRawPackets([][]byte{m.sectionsBytes.Bytes()})
+		if err != nil {
+			return bytesWritten, err
+		}
+		bytesWritten += n
+
+		st.last = tableRetransmission{hasLast: true, last: t}
+	}
+	return bytesWritten, nil
+}
+
+// muxerEITSchedule represents an EIT schedule whose events are laid out over segments starting at midnight UTC of the
+// day of the UTC time it's written at
+type muxerEITSchedule struct {
+	actual  bool
+	d       *EITData
+	day     time.Time // midnight UTC of the day events have been laid out for
+	ss      []*PSISection
+	utc     *muxerUTCTime
+	version *wrappingCounter
+}
+
+// sections returns the sections at t, in 27MHz units, and lays out events again if the day has changed
+func (s *muxerEITSchedule) sections(t int64) ([]*PSISection, error) {
+	if utc := s.utc.at(t); utc.UTC().Truncate(24 * time.Hour).After(s.day) {
+		if err := s.layOut(utc); err != nil {
+			return nil, fmt.Errorf("astits: laying out EIT schedule events failed: %w", err)
+		}
+	}
+	return s.ss, nil
+}
+
+// layOut lays out events over segments starting at midnight UTC of the day of utc, and increments the version
+func (s *muxerEITSchedule) layOut(utc time.Time) error {
+	version := *s.version
+	ss, err := newEITScheduleSections(s.d, s.actual, utc, uint8(version.inc()))
+	if err != nil {
+		return err
+	}
+	*s.version = version
+	s.day = utc.UTC().Truncate(24 * time.Hour)
+	s.ss = ss
+	return nil
+}
+
+// muxerUTCTime advances a UTC time with the stream time
+// Only positive deltas between consecutive stream times are added so that the UTC time never goes backwards
+type muxerUTCTime struct {
+	hasLast bool
+	last    int64 // stream time of the UTC time, in 27MHz units
+	utc     time.Time
+}
+
+func newMuxerUTCTime(utc time.Time) *muxerUTCTime {
+	return &muxerUTCTime{utc: utc}
+}
+
+// at returns the UTC time at t, in 27MHz units
+func (u *muxerUTCTime) at(t int64) time.Time {
+	if u.hasLast && t > u.last {
+		u.utc = u.utc.Add(time.Duration((t - u.last) * 1000 / 27))
+	}
+	u.hasLast = true
+	u.last = t
+	return u.utc
+}
@@ -0,0 +1,216 @@
+package astits
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMuxerSITables(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+	muxer.SetPCRPID(0x100)
+
+	utc := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, muxer.SetNIT(&NITData{NetworkID: 1}, true, time.Second))
+	assert.NoError(t, muxer.SetSDT(&SDTData{Services: []*SDTDataService{{ServiceID: 1}}, TransportStreamID: 1}, true, 0))
+	assert.NoError(t, muxer.SetEITPresentFollowing(&EITData{ServiceID: 1}, true, 0))
+	assert.NoError(t, muxer.SetTOT(&TOTData{UTCTime: utc}, time.Second))
+	assert.NoError(t, muxer.SetTDT(&TDTData{UTCTime: utc}, 0))
+	assert.NoError(t, muxer.RemoveSITable(PSITableIDTDT, 0))
+	assert.Equal(t, ErrSITableNotFound, muxer.RemoveSITable(PSITableIDTDT, 0))
+
+	// One PES packet every 500ms
+	for i := 0; i <= 10; i++ {
+		_, err := muxer.WriteData(&MuxerData{
+			PES: &PESData{
+				Data: []byte("test"),
+				Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+					PTS:             &ClockReference{Base: int64(i) * 45000},
+					PTSDTSIndicator: PTSDTSIndicatorOnlyPTS,
+				}},
+			},
+			PID: 0x100,
+		})
+		assert.NoError(t, err)
+
+		// Update SDT
+		if i == 5 {
+			assert.NoError(t, muxer.SetSDT(&SDTData{Services: []*SDTDataService{{ServiceID: 2}}, TransportStreamID: 1}, true, 0))
+			assert.Equal(t, 1, muxer.siTableVersion(siTableKey(PSITableIDSDTVariant1, 1)).get())
+		}
+	}
+
+	// Check packets
+	names := map[uint16]string{PIDPAT: "PAT", pmtStartPID: "PMT", PIDNIT: "NIT", PIDSDT: "SDT", PIDEIT: "EIT", PIDTDT: "TOT", 0x100: "PES"}
+	var actual []string
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PayloadUnitStartIndicator {
+			actual = append(actual, names[p.Header.PID])
+		}
+	}
+	for _, s := range dmx.Stats().PIDs {
+		assert.Equal(t, int64(0), s.ContinuityCounterErrors)
+	}
+	assert.Equal(t, []string{
+		"PAT", "PMT", "NIT", "SDT", "EIT", "EIT", "TOT", "PES", // 0s
+		"PES",
+		"NIT", "TOT", "PES", // 1s
+		"PES",
+		"NIT", "SDT", "EIT", "EIT", "TOT", "PES", // 2s
+		"PES",
+		"NIT", "SDT", "TOT", "PES", // 3s, SDT has been updated
+		"PES",
+		"NIT", "EIT", "EIT", "TOT", "PES", // 4s
+		"PES",
+		"NIT", "SDT", "TOT", "PES", // 5s
+	}, actual)
+
+	// Check tables
+	var serviceIDs []uint16
+	var utcs []time.Time
+	dmx = NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		d, err := dmx.NextData()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		switch {
+		case d.SDT != nil:
+			serviceIDs = append(serviceIDs, d.SDT.Services[0].ServiceID)
+		case d.TOT != nil:
+			utcs = append(utcs, d.TOT.UTCTime)
+		}
+	}
+	assert.Equal(t, []uint16{1, 1, 2, 2}, serviceIDs)
+	assert.Equal(t, []time.Time{utc, utc.Add(time.Second), utc.Add(2 * time.Second), utc.Add(3 * time.Second), utc.Add(4 * time.Second), utc.Add(5 * time.Second)}, utcs)
+}
+
+func TestMuxerSITablesUTCTime(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+	muxer.SetPCRPID(0x100)
+
+	utc := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, muxer.SetTOT(&TOTData{UTCTime: utc}, time.Second))
+
+	for _, pts := range []int64{
+		1<<33 - 90000,
+		-1,     // No PTS
+		0,      // Wrap, 1s later
+		-1,     // No PTS
+		90000,  // 2s
+		45000,  // Jitter
+		180000, // 3s
+		0,      // Discontinuity
+		90000,  // 4s
+	} {
+		h := &PESHeader{OptionalHeader: &PESOptionalHeader{}}
+		if pts >= 0 {
+			h.OptionalHeader.PTS = &ClockReference{Base: pts}
+			h.OptionalHeader.PTSDTSIndicator = PTSDTSIndicatorOnlyPTS
+		}
+		_, err := muxer.WriteData(&MuxerData{PES: &PESData{Data: []byte("test"), Header: h}, PID: 0x100})
+		assert.NoError(t, err)
+	}
+
+	// UTC time never goes backwards
+	var utcs []time.Time
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		d, err := dmx.NextData()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if d.TOT != nil {
+			utcs = append(utcs, d.TOT.UTCTime)
+		}
+	}
+	assert.Equal(t, []time.Time{utc, utc.Add(time.Second), utc.Add(2 * time.Second), utc.Add(3 * time.Second), utc.Add(4 * time.Second)}, utcs)
+}
+
+func TestMuxerSITableVersion(t *testing.T) {
+	muxer := NewMuxer(context.Background(), &bytes.Buffer{})
+	key := siTableKey(PSITableIDSDTVariant1, 1)
+	versionNumber := func() uint8 {
+		ss, err := muxer.siTables[key].sections(0)
+		assert.NoError(t, err)
+		return ss[0].Syntax.Header.VersionNumber
+	}
+
+	d := &SDTData{Services: []*SDTDataService{{ServiceID: 1}}, TransportStreamID: 1}
+	assert.NoError(t, muxer.SetSDT(d, true, 0))
+	assert.Equal(t, uint8(0), versionNumber())
+	assert.NoError(t, muxer.SetSDT(d, true, 0))
+	assert.Equal(t, uint8(1), versionNumber())
+
+	// Version is kept once the table is removed
+	assert.NoError(t, muxer.RemoveSITable(PSITableIDSDTVariant1, 1))
+	assert.NoError(t, muxer.SetSDT(d, true, 0))
+	assert.Equal(t, uint8(2), versionNumber())
+}
+
+func TestMuxerEITSchedule(t *testing.T) {
+	muxer := NewMuxer(context.Background(), &bytes.Buffer{})
+	key := siTableKey(eitTableIDScheduleActual, 1)
+	midnight := time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC)
+	events := func(st int64) (sectionNumbers []uint8, eventIDs []uint16, versionNumber uint8) {
+		ss, err := muxer.siTables[key].sections(st)
+		assert.NoError(t, err)
+		for _, s := range ss {
+			if len(s.Syntax.Data.EIT.Events) > 0 {
+				sectionNumbers = append(sectionNumbers, s.Syntax.Header.SectionNumber)
+			}
+			for _, e := range s.Syntax.Data.EIT.Events {
+				eventIDs = append(eventIDs, e.EventID)
+			}
+			versionNumber = s.Syntax.Header.VersionNumber
+		}
+		return
+	}
+
+	// Events are laid out again when the day changes with the stream time
+	assert.NoError(t, muxer.SetEITSchedule(&EITData{Events: []*EITDataEvent{
+		{Duration: time.Hour, EventID: 1, StartTime: midnight.Add(-2 * time.Hour)},
+		{Duration: time.Hour, EventID: 2, StartTime: midnight.Add(time.Hour)},
+	}, ServiceID: 1}, true, midnight.Add(-time.Second), 0))
+	sectionNumbers, eventIDs, versionNumber := events(0)
+	assert.Equal(t, []uint8{56, 64}, sectionNumbers)
+	assert.Equal(t, []uint16{1, 2}, eventIDs)
+	assert.Equal(t, uint8(0), versionNumber)
+	sectionNumbers, eventIDs, versionNumber = events(27000000)
+	assert.Equal(t, []uint8{0}, sectionNumbers)
+	assert.Equal(t, []uint16{2}, eventIDs)
+	assert.Equal(t, uint8(1), versionNumber)
+	_, _, versionNumber = events(2 * 27000000)
+	assert.Equal(t, uint8(1), versionNumber)
+
+	// Events that don't fit in a segment once laid out again make writing fail
+	ds := []*Descriptor{{Length: 250, Tag: 0x80, UserDefined: make([]byte, 250)}}
+	var es []*EITDataEvent
+	for i := 0; i < 70; i++ {
+		es = append(es,
+			&EITDataEvent{Descriptors: ds, Duration: 3 * time.Hour, StartTime: midnight.Add(-2 * time.Hour)},
+			&EITDataEvent{Descriptors: ds, Duration: time.Hour, StartTime: midnight.Add(time.Hour)},
+		)
+	}
+	assert.NoError(t, muxer.SetEITSchedule(&EITData{Events: es, ServiceID: 1}, true, midnight.Add(-time.Second), 0))
+	_, err := muxer.siTables[key].sections(0)
+	assert.NoError(t, err)
+	_, err = muxer.siTables[key].sections(27000000)
+	assert.True(t, errors.Is(err, ErrEITSegmentTooLarge))
+}
@@ -3,7 +3,9 @@ package astits
 import (
 	"bytes"
 	"context"
+	"log"
 	"testing"
+	"time"
 
 	"github.com/asticode/go-astikit"
 	"github.com/stretchr/testify/assert"
@@ -165,17 +167,18 @@ func TestMuxer_generatePMT(t *testing.T) {
 	})
 	muxer.SetPCRPID(0x1234)
 	assert.NoError(t, err)
+	p := muxer.programs[uint32(programNumberStart)]
 
-	err = muxer.generatePMT()
+	err = muxer.generatePMT(p)
 	assert.NoError(t, err)
-	assert.Equal(t, MpegTsPacketSize, muxer.pmtBytes.Len())
-	assert.Equal(t, pmtExpectedBytesVideoOnly(0, 0), muxer.pmtBytes.Bytes())
+	assert.Equal(t, MpegTsPacketSize, p.pmtBytes.Len())
+	assert.Equal(t, pmtExpectedBytesVideoOnly(0, 0), p.pmtBytes.Bytes())
 
 	// Version number shouldn't change
-	err = muxer.generatePMT()
+	err = muxer.generatePMT(p)
 	assert.NoError(t, err)
-	assert.Equal(t, MpegTsPacketSize, muxer.pmtBytes.Len())
-	assert.Equal(t, pmtExpectedBytesVideoOnly(0, 1), muxer.pmtBytes.Bytes())
+	assert.Equal(t, MpegTsPacketSize, p.pmtBytes.Len())
+	assert.Equal(t, pmtExpectedBytesVideoOnly(0, 1), p.pmtBytes.Bytes())
 
 	err = muxer.AddElementaryStream(PMTElementaryStream{
 		ElementaryPID: 0x0234,
@@ -184,10 +187,10 @@ func TestMuxer_generatePMT(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Version number should change
-	err = muxer.generatePMT()
+	err = muxer.generatePMT(p)
 	assert.NoError(t, err)
-	assert.Equal(t, MpegTsPacketSize, muxer.pmtBytes.Len())
-	assert.Equal(t, pmtExpectedBytesVideoAndAudio(1, 2), muxer.pmtBytes.Bytes())
+	assert.Equal(t, MpegTsPacketSize, p.pmtBytes.Len())
+	assert.Equal(t, pmtExpectedBytesVideoAndAudio(1, 2), p.pmtBytes.Bytes())
 }
 
 func TestMuxer_WriteTables(t *testing.T) {
@@ -334,3 +337,693 @@ func TestMuxer_WritePayload(t *testing.T) {
 	assert.Equal(t, patExpectedBytes(0, 0), bs[:MpegTsPacketSize])
 	assert.Equal(t, pmtExpectedBytesVideoAndAudio(0, 0), bs[MpegTsPacketSize:MpegTsPacketSize*2])
 }
+
+func TestMuxer_PacketSize192(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPacketSize(m2tsPacketSize), MuxerOptMuxRate(MpegTsPacketSize*8*1000))
+	err := muxer.AddElementaryStream(PMTElementaryStream{
+		ElementaryPID: 0x1234,
+		StreamType:    StreamTypeH264Video,
+	})
+	muxer.SetPCRPID(0x1234)
+	assert.NoError(t, err)
+
+	n, err := muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, 2*m2tsPacketSize, n)
+	assert.Equal(t, n, buf.Len())
+	assert.Equal(t, patExpectedBytes(0, 0), buf.Bytes()[tpExtraHeaderSize:m2tsPacketSize])
+	assert.Equal(t, pmtExpectedBytesVideoOnly(0, 0), buf.Bytes()[m2tsPacketSize+tpExtraHeaderSize:])
+
+	n, err = muxer.WritePacket(&Packet{
+		AdaptationField: &PacketAdaptationField{
+			HasPCR:         true,
+			PCR:            &ClockReference{Base: 900},
+			StuffingLength: MpegTsPacketSize - 4 - 2 - pcrBytesSize,
+		},
+		Header: PacketHeader{HasAdaptationField: true, PID: 0x1234},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, m2tsPacketSize, n)
+
+	n, err = muxer.WritePacket(&Packet{Header: PacketHeader{HasPayload: true, PID: 0x1234}, Payload: []byte{0x1}})
+	assert.NoError(t, err)
+	assert.Equal(t, m2tsPacketSize, n)
+
+	// One packet every ms
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for _, ats := range []uint32{0, 27000, 270000, 297000} {
+		p, err := dmx.NextPacket()
+		assert.NoError(t, err)
+		assert.Equal(t, &PacketTPExtraHeader{ArrivalTimeStamp: ats}, p.TPExtraHeader)
+	}
+}
+
+func TestMuxer_Programs(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x1234, StreamType: StreamTypeH264Video}))
+	muxer.SetPCRPID(0x1234)
+
+	// Errors
+	assert.Equal(t, ErrProgramAlreadyExists, muxer.AddProgram(0x1100, PMTData{ProgramNumber: programNumberStart}))
+	assert.Equal(t, ErrPIDAlreadyExists, muxer.AddProgram(pmtStartPID, PMTData{ProgramNumber: 2}))
+	assert.Equal(t, ErrPIDAlreadyExists, muxer.AddProgram(0x1100, PMTData{
+		ElementaryStreams: []*PMTElementaryStream{{ElementaryPID: 0x1234, StreamType: StreamTypeAACAudio}},
+		ProgramNumber:     2,
+	}))
+	assert.Equal(t, ErrProgramNotFound, muxer.AddProgramElementaryStream(2, PMTElementaryStream{}))
+	assert.Equal(t, ErrProgramNotFound, muxer.RemoveProgram(2))
+
+	// Add program
+	assert.NoError(t, muxer.AddProgram(0x1100, PMTData{
+		ElementaryStreams:  []*PMTElementaryStream{{StreamType: StreamTypeAACAudio}},
+		PCRPID:             startPID,
+		ProgramDescriptors: []*Descriptor{{Tag: DescriptorTagISO639LanguageAndAudioType, Length: 4, ISO639LanguageAndAudioType: &DescriptorISO639LanguageAndAudioType{Language: []byte("eng"), Type: AudioTypeCleanEffects}}},
+		ProgramNumber:      2,
+	}))
+	_, err := muxer.WriteTables()
+	assert.NoError(t, err)
+
+	// Update program
+	assert.NoError(t, muxer.AddProgramElementaryStream(2, PMTElementaryStream{ElementaryPID: 0x301, StreamType: StreamTypeMPEG1Audio}))
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	// Remove program
+	assert.NoError(t, muxer.RemoveProgram(programNumberStart))
+	_, err = muxer.WriteTables()
+	assert.NoError(t, err)
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	type table struct {
+		pid      uint16
+		programs []uint16
+		streams  []uint16
+	}
+	var ts []table
+	for {
+		d, err := dmx.NextData()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if d.PAT != nil {
+			var ps []uint16
+			for _, p := range d.PAT.Programs {
+				ps = append(ps, p.ProgramNumber)
+			}
+			ts = append(ts, table{pid: d.PID, programs: ps})
+		} else if d.PMT != nil {
+			var ss []uint16
+			for _, es := range d.PMT.ElementaryStreams {
+				ss = append(ss, es.ElementaryPID)
+			}
+			ts = append(ts, table{pid: d.PID, programs: []uint16{d.PMT.ProgramNumber}, streams: ss})
+		}
+	}
+	assert.Equal(t, []table{
+		{pid: PIDPAT, programs: []uint16{1, 2}},
+		{pid: pmtStartPID, programs: []uint16{1}, streams: []uint16{0x1234}},
+		{pid: 0x1100, programs: []uint16{2}, streams: []uint16{startPID}},
+		{pid: PIDPAT, programs: []uint16{1, 2}},
+		{pid: pmtStartPID, programs: []uint16{1}, streams: []uint16{0x1234}},
+		{pid: 0x1100, programs: []uint16{2}, streams: []uint16{startPID, 0x301}},
+		{pid: PIDPAT, programs: []uint16{2}},
+		{pid: 0x1100, programs: []uint16{2}, streams: []uint16{startPID, 0x301}},
+	}, ts)
+	assert.Equal(t, 1, muxer.patVersion.get())
+	assert.Equal(t, 1, muxer.programs[2].pmtVersion.get())
+}
+
+func TestMuxer_LargeTables(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+
+	// PMT spanning several packets
+	for i := 0; i < 60; i++ {
+		assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{
+			ElementaryStreamDescriptors: []*Descriptor{{
+				ISO639LanguageAndAudioType: &DescriptorISO639LanguageAndAudioType{Language: []byte("eng"), Type: AudioTypeCleanEffects},
+				Length:                     4,
+				Tag:                        DescriptorTagISO639LanguageAndAudioType,
+			}},
+			StreamType: StreamTypeAACAudio,
+		}))
+	}
+	muxer.SetPCRPID(startPID)
+
+	// PAT spanning several sections
+	muxer.nextPID = 0x200
+	for i := uint16(0); i < 300; i++ {
+		assert.NoError(t, muxer.AddProgram(0x1001+i, PMTData{
+			ElementaryStreams: []*PMTElementaryStream{{StreamType: StreamTypeH264Video}},
+			PCRPID:            0x200 + i,
+			ProgramNumber:     2 + i,
+		}))
+	}
+
+	n, err := muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n%MpegTsPacketSize)
+	assert.Equal(t, n, buf.Len())
+
+	var pat *PATData
+	var pmt *PMTData
+	var pmts int
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()), DemuxerOptPSITableAssembly(0))
+	for {
+		d, err := dmx.NextData()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if d.PAT != nil {
+			pat = d.PAT
+		} else if d.PMT != nil {
+			if d.PMT.ProgramNumber == programNumberStart {
+				pmt = d.PMT
+			}
+			pmts++
+		}
+	}
+	assert.NotNil(t, pat)
+	assert.Len(t, pat.Programs, 301)
+	assert.NotNil(t, pmt)
+	assert.Len(t, pmt.ElementaryStreams, 60)
+	assert.Equal(t, 301, pmts)
+
+	// PMT doesn't fit in a section
+	for i := 0; i < 80; i++ {
+		assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{StreamType: StreamTypeAACAudio}))
+	}
+	_, err = muxer.WriteTables()
+	assert.Equal(t, ErrPMTTooLarge, err)
+}
+
+func TestMuxer_PCR(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptAutodetectPCRPID(), MuxerOptMuxDelay(20*time.Millisecond), MuxerOptPCRInterval(time.Second))
+	assert.Equal(t, maxPCRInterval, muxer.pcrInterval)
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x101, StreamType: StreamTypeAACAudio}))
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x102, StreamType: StreamTypeMetadata}))
+
+	// Audio is used when there's no video
+	_, err := muxer.WriteTables()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x101), muxer.programs[uint32(programNumberStart)].pmt.PCRPID)
+
+	// Video is preferred
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+	assert.NoError(t, muxer.RemoveElementaryStream(0x101))
+
+	// PCRs are 20ms before the DTS
+	for _, v := range []struct {
+		dts int64
+		pid uint16
+	}{
+		{dts: 1800, pid: 0x102}, // PCR packet
+		{dts: 3600, pid: 0x100}, // No PCR
+		{dts: 5400, pid: 0x100}, // PCR
+		{dts: 5800, pid: 0x102}, // No PCR
+		{dts: 9000, pid: 0x102}, // PCR packet
+	} {
+		_, err = muxer.WriteData(&MuxerData{
+			PES: &PESData{
+				Data: []byte("test"),
+				Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+					DTS:             &ClockReference{Base: v.dts},
+					PTS:             &ClockReference{Base: v.dts},
+					PTSDTSIndicator: PTSDTSIndicatorBothPresent,
+				}},
+			},
+			PID: v.pid,
+		})
+		assert.NoError(t, err)
+	}
+
+	type pcr struct {
+		base    int64
+		cc      uint8
+		payload bool
+	}
+	var pcrs []pcr
+	var ccs []uint8
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PID != 0x100 {
+			continue
+		}
+		ccs = append(ccs, p.Header.ContinuityCounter)
+		if p.Header.HasAdaptationField && p.AdaptationField.HasPCR {
+			pcrs = append(pcrs, pcr{base: p.AdaptationField.PCR.Base, cc: p.Header.ContinuityCounter, payload: p.Header.HasPayload})
+		}
+	}
+	assert.Equal(t, []pcr{{base: 0}, {base: 3600, cc: 2, payload: true}, {base: 7200, cc: 2}}, pcrs)
+	assert.Equal(t, []uint8{0, 1, 2, 2}, ccs)
+
+	// PCR is the default mux delay before the DTS, and wraps around
+	for _, v := range []struct {
+		dts int64
+		pcr int64
+	}{
+		{dts: 90000, pcr: 45000},
+		{dts: 9000, pcr: 1<<33 - 36000},
+	} {
+		buf.Reset()
+		muxer = NewMuxer(context.Background(), &buf, MuxerOptPCRInterval(maxPCRInterval))
+		assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+		muxer.SetPCRPID(0x100)
+		_, err = muxer.WriteData(&MuxerData{
+			PES: &PESData{
+				Data: []byte("test"),
+				Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+					DTS:             &ClockReference{Base: v.dts},
+					PTS:             &ClockReference{Base: v.dts},
+					PTSDTSIndicator: PTSDTSIndicatorBothPresent,
+				}},
+			},
+			PID: 0x100,
+		})
+		assert.NoError(t, err)
+		dmx = NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+		var pcrs []int64
+		for {
+			p, err := dmx.NextPacket()
+			if err == ErrNoMorePackets {
+				break
+			}
+			assert.NoError(t, err)
+			if p.Header.HasAdaptationField && p.AdaptationField.HasPCR {
+				pcrs = append(pcrs, p.AdaptationField.PCR.Base)
+			}
+		}
+		assert.Equal(t, []int64{v.pcr}, pcrs)
+	}
+}
+
+func TestMuxer_PCRInterleaved(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptPCRInterval(maxPCRInterval))
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x101, StreamType: StreamTypeAACAudio}))
+	muxer.SetPCRPID(0x100)
+
+	for _, v := range []struct {
+		discontinuity bool
+		dts           int64
+		pid           uint16
+	}{
+		{dts: 90000, pid: 0x100},                      // PCR
+		{dts: 88200, pid: 0x101},                      // Earlier than the last PCR
+		{dts: 93000, pid: 0x100},                      // Not due
+		{dts: 93600, pid: 0x101},                      // PCR packet
+		{dts: 1<<33 - 100, pid: 0x100},                // Jump that is not signalled
+		{dts: 54000, pid: 0x100, discontinuity: true}, // PCR starts over
+	} {
+		_, err := muxer.WriteData(&MuxerData{
+			AdaptationField: &PacketAdaptationField{DiscontinuityIndicator: v.discontinuity},
+			PES: &PESData{
+				Data: []byte("test"),
+				Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+					DTS:             &ClockReference{Base: v.dts},
+					PTS:             &ClockReference{Base: v.dts},
+					PTSDTSIndicator: PTSDTSIndicatorBothPresent,
+				}},
+			},
+			PID: v.pid,
+		})
+		assert.NoError(t, err)
+	}
+
+	var pcrs []int64
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.HasAdaptationField && p.AdaptationField.HasPCR {
+			pcrs = append(pcrs, p.AdaptationField.PCR.Base)
+		}
+	}
+	assert.Equal(t, []int64{45000, 48600, 9000}, pcrs)
+}
+
+func TestMuxer_ConstantBitrate(t *testing.T) {
+	// 1000 packets per second
+	buf := bytes.Buffer{}
+	logBuf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptConstantBitrate(MpegTsPacketSize*8*1000), MuxerOptAutodetectPCRPID(), MuxerOptLogger(log.New(&logBuf, "", 0)))
+	assert.Equal(t, maxPCRInterval, muxer.pcrInterval)
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+
+	for _, dts := range []int64{
+		90000, // Sets the origin 500ms before
+		99000, // 100ms later
+		50490, // Late
+	} {
+		_, err := muxer.WriteData(&MuxerData{
+			PES: &PESData{
+				Data: []byte("test"),
+				Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+					DTS:             &ClockReference{Base: dts},
+					PTS:             &ClockReference{Base: dts},
+					PTSDTSIndicator: PTSDTSIndicatorBothPresent,
+				}},
+			},
+			PID: 0x100,
+		})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "astits: mux rate of 1504000 bps exceeded: PES packet on PID 256 is written 40ms after its timestamp\n", logBuf.String())
+
+	var nulls int
+	var pcrs, pcrIdxs []int64
+	var idx int64
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for ; ; idx++ {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PID == PIDNull {
+			nulls++
+		}
+		if p.Header.HasAdaptationField && p.AdaptationField.HasPCR {
+			pcrs = append(pcrs, p.AdaptationField.PCR.Base)
+			pcrIdxs = append(pcrIdxs, idx)
+		}
+	}
+
+	// PAT, PMT and PES, nulls and PCRs until the second PES which is due 100 packets later, then both PES
+	assert.Equal(t, int64(102), idx)
+	assert.Equal(t, 95, nulls)
+	assert.Equal(t, []int64{2, 42, 82}, pcrIdxs)
+	for i, pcr := range pcrs {
+		assert.Equal(t, 45000+pcrIdxs[i]*90, pcr)
+	}
+}
+
+func TestMuxer_ConstantBitrateLargePES(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptConstantBitrate(2000000))
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+	muxer.SetPCRPID(0x100)
+	assert.NoError(t, muxer.AddProgram(0x1001, PMTData{ProgramNumber: 2}))
+	assert.NoError(t, muxer.AddProgramElementaryStream(2, PMTElementaryStream{ElementaryPID: 0x200, StreamType: StreamTypeH264Video}))
+	assert.NoError(t, muxer.SetProgramPCRPID(2, 0x200))
+
+	// PES packet spanning over several PCR intervals
+	_, err := muxer.WriteData(&MuxerData{
+		PES: &PESData{
+			Data: bytes.Repeat([]byte{0x1}, 100000),
+			Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+				DTS:             &ClockReference{Base: 90000},
+				PTS:             &ClockReference{Base: 90000},
+				PTSDTSIndicator: PTSDTSIndicatorBothPresent,
+			}},
+		},
+		PID: 0x100,
+	})
+	assert.NoError(t, err)
+
+	// PCRs match the position of their packet and are never more than the PCR interval apart, give or take a packet
+	maxGap := int64(maxPCRInterval)*27/1000 + muxer.packetsDuration(1)
+	lastPCRs := make(map[uint16]int64)
+	var idx int64
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for ; ; idx++ {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if !p.Header.HasAdaptationField || !p.AdaptationField.HasPCR {
+			continue
+		}
+		pcr := p.AdaptationField.PCR.Base*300 + p.AdaptationField.PCR.Extension
+		assert.Equal(t, muxer.cbrOrigin+muxer.packetsDuration(idx), pcr)
+		if last, ok := lastPCRs[p.Header.PID]; ok {
+			assert.True(t, pcr-last <= maxGap, "PID %d: %d", p.Header.PID, pcr-last)
+		}
+		lastPCRs[p.Header.PID] = pcr
+	}
+	assert.True(t, idx > 500)
+	assert.Len(t, lastPCRs, 2)
+	assert.True(t, muxer.cbrOrigin+muxer.packetsDuration(idx)-lastPCRs[0x100] <= maxGap)
+}
+
+func TestMuxer_TablesRetransmitInterval(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf,
+		MuxerOptTablesRetransmitInterval(PSITableTypePAT, 100*time.Millisecond),
+		MuxerOptTablesRetransmitInterval(PSITableTypePMT, 200*time.Millisecond),
+	)
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+	muxer.SetPCRPID(0x100)
+
+	// One PES packet every 20ms
+	for i := 0; i < 11; i++ {
+		_, err := muxer.WriteData(&MuxerData{
+			// Random access forces tables
+			AdaptationField: &PacketAdaptationField{RandomAccessIndicator: i == 3},
+			PES: &PESData{
+				Data: []byte("test"),
+				Header: &PESHeader{OptionalHeader: &PESOptionalHeader{
+					DTS:             &ClockReference{Base: int64(i) * 1800},
+					PTS:             &ClockReference{Base: int64(i) * 1800},
+					PTSDTSIndicator: PTSDTSIndicatorBothPresent,
+				}},
+			},
+			PID: 0x100,
+		})
+		assert.NoError(t, err)
+	}
+
+	// Get the index of the PES packets preceded by tables
+	var pes int
+	var pats, pmts []int
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		switch p.Header.PID {
+		case PIDPAT:
+			pats = append(pats, pes)
+		case pmtStartPID:
+			pmts = append(pmts, pes)
+		default:
+			pes++
+		}
+	}
+	assert.Equal(t, []int{0, 3, 8}, pats)
+	assert.Equal(t, []int{0, 3}, pmts)
+}
+
+func TestMuxer_TablesRetransmitIntervalStreamTime(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf,
+		MuxerOptTablesRetransmitInterval(PSITableTypePAT, 100*time.Millisecond),
+		MuxerOptTablesRetransmitInterval(PSITableTypePMT, 200*time.Millisecond),
+	)
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x101, StreamType: StreamTypeMetadata}))
+	assert.NoError(t, muxer.AddProgram(0x1001, PMTData{ProgramNumber: 2}))
+	assert.NoError(t, muxer.AddProgramElementaryStream(2, PMTElementaryStream{ElementaryPID: 0x200, StreamType: StreamTypeH264Video}))
+	muxer.SetPCRPID(0x100)
+	assert.NoError(t, muxer.SetProgramPCRPID(2, 0x200))
+
+	writePES := func(pid uint16, ts *ClockReference) {
+		h := &PESHeader{OptionalHeader: &PESOptionalHeader{}}
+		if ts != nil {
+			h.OptionalHeader.PTS = ts
+			h.OptionalHeader.PTSDTSIndicator = PTSDTSIndicatorOnlyPTS
+		}
+		_, err := muxer.WriteData(&MuxerData{PES: &PESData{Data: []byte("test"), Header: h}, PID: pid})
+		assert.NoError(t, err)
+	}
+
+	// Every 20ms, programs are on unrelated clocks, the clock of the second program wraps, and PES packets without
+	// timestamp are written in between
+	for i := int64(0); i < 11; i++ {
+		writePES(0x100, &ClockReference{Base: i * 1800})
+		writePES(0x200, &ClockReference{Base: (1<<33 - 5*1800 + i*1800) % (1 << 33)})
+		writePES(0x101, nil)
+	}
+
+	// Get the index of the PES packets preceded by tables
+	var pes int
+	var pats, pmts1, pmts2 []int
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		switch p.Header.PID {
+		case PIDPAT:
+			pats = append(pats, pes)
+		case pmtStartPID:
+			pmts1 = append(pmts1, pes)
+		case 0x1001:
+			pmts2 = append(pmts2, pes)
+		default:
+			pes++
+		}
+	}
+	assert.Equal(t, []int{0, 15, 30}, pats)
+	assert.Equal(t, []int{0, 30}, pmts1)
+	assert.Equal(t, []int{0, 30}, pmts2)
+}
+
+func TestMuxer_NetworkPlan(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf, MuxerOptTransportStreamID(0x1234), MuxerOptOriginalNetworkID(0x5678), MuxerOptNetworkPID(PIDNIT))
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+	muxer.SetPCRPID(0x100)
+
+	// PMT PID
+	assert.Equal(t, ErrProgramNotFound, muxer.SetProgramPMTPID(2, 0x200))
+	assert.Equal(t, ErrPIDAlreadyExists, muxer.SetProgramPMTPID(programNumberStart, PIDNIT))
+	assert.Equal(t, ErrPIDAlreadyExists, muxer.SetProgramPMTPID(programNumberStart, 0x100))
+	assert.NoError(t, muxer.SetProgramPMTPID(programNumberStart, 0x200))
+
+	// IDs of the actual SDT are filled
+	assert.NoError(t, muxer.SetSDT(&SDTData{Services: []*SDTDataService{{ServiceID: 1}}}, true, 0))
+
+	_, err := muxer.WriteData(&MuxerData{PES: &PESData{Data: []byte("test"), Header: &PESHeader{}}, PID: 0x100})
+	assert.NoError(t, err)
+
+	var pat *PATData
+	var pmtPID uint16
+	var sdt *SDTData
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		d, err := dmx.NextData()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		switch {
+		case d.PAT != nil:
+			pat = d.PAT
+		case d.PMT != nil:
+			pmtPID = d.PID
+		case d.SDT != nil:
+			sdt = d.SDT
+		}
+	}
+	assert.Equal(t, &PATData{
+		Programs: []*PATProgram{
+			{ProgramMapID: PIDNIT},
+			{ProgramMapID: 0x200, ProgramNumber: programNumberStart},
+		},
+		TransportStreamID: 0x1234,
+	}, pat)
+	assert.Equal(t, uint16(0x200), pmtPID)
+	assert.Equal(t, uint16(0x1234), sdt.TransportStreamID)
+	assert.Equal(t, uint16(0x5678), sdt.OriginalNetworkID)
+
+	// NIT is written on the PID listed in the PAT
+	buf.Reset()
+	muxer = NewMuxer(context.Background(), &buf, MuxerOptNetworkPID(0x1ff))
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}))
+	muxer.SetPCRPID(0x100)
+	assert.NoError(t, muxer.SetNIT(&NITData{NetworkID: 1}, true, 0))
+	_, err = muxer.WriteData(&MuxerData{PES: &PESData{Data: []byte("test"), Header: &PESHeader{}}, PID: 0x100})
+	assert.NoError(t, err)
+
+	var nitPIDs []uint16
+	dmx = NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		if p.Header.PayloadUnitStartIndicator && PSITableID(p.Payload[int(p.Payload[0])+1]) == PSITableIDNITVariant1 {
+			nitPIDs = append(nitPIDs, p.Header.PID)
+		}
+	}
+	assert.Equal(t, []uint16{0x1ff}, nitPIDs)
+
+	// PMT PID of the default program doesn't collide with the network PID
+	muxer = NewMuxer(context.Background(), &bytes.Buffer{}, MuxerOptNetworkPID(pmtStartPID))
+	assert.Equal(t, pmtStartPID+1, muxer.programs[uint32(programNumberStart)].pmtPID)
+	assert.Equal(t, ErrPIDAlreadyExists, muxer.SetProgramPMTPID(programNumberStart, pmtStartPID))
+	d, err := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes())).NextData()
+	assert.NoError(t, err)
+	assert.Equal(t, &PATProgram{ProgramMapID: 0x1ff}, d.PAT.Programs[0])
+}
+
+func TestMuxer_WriteSections(t *testing.T) {
+	buf := bytes.Buffer{}
+	muxer := NewMuxer(context.Background(), &buf)
+	assert.NoError(t, muxer.AddElementaryStream(PMTElementaryStream{ElementaryPID: 0x1f0, StreamType: StreamTypeSCTE35}))
+
+	// PAT and PMT PIDs are not allowed
+	_, err := muxer.WriteData(&MuxerData{PID: PIDPAT, PrivateSections: []*PrivateSection{{TableID: 0xfc}}})
+	assert.Equal(t, ErrPIDAlreadyExists, err)
+	_, err = muxer.WriteData(&MuxerData{PID: pmtStartPID, PrivateSections: []*PrivateSection{{TableID: 0xfc}}})
+	assert.Equal(t, ErrPIDAlreadyExists, err)
+
+	// Private sections spanning over several packets
+	data := bytes.Repeat([]byte{0x1}, 300)
+	n, err := muxer.WriteData(&MuxerData{PID: 0x1f0, PrivateSections: []*PrivateSection{
+		{Data: data, HasCRC32: true, TableID: 0xfc},
+		{Data: []byte("test"), TableID: 0xfc},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3*MpegTsPacketSize, n)
+
+	// PSI section on a PID that is not listed in a PMT
+	n, err = muxer.WriteData(&MuxerData{PID: PIDTDT, Sections: []*PSISection{newTDTSection(&TDTData{UTCTime: dvbTime})}})
+	assert.NoError(t, err)
+	assert.Equal(t, MpegTsPacketSize, n)
+
+	dmx := NewDemuxer(context.Background(), bytes.NewReader(buf.Bytes()))
+	var ps []*Packet
+	for {
+		p, err := dmx.NextPacket()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		ps = append(ps, p)
+	}
+	assert.Len(t, ps, 4)
+
+	// First section
+	assert.Equal(t, uint16(0x1f0), ps[0].Header.PID)
+	assert.True(t, ps[0].Header.PayloadUnitStartIndicator)
+	assert.Equal(t, uint8(0), ps[0].Header.ContinuityCounter)
+	assert.False(t, ps[1].Header.PayloadUnitStartIndicator)
+	assert.Equal(t, uint8(1), ps[1].Header.ContinuityCounter)
+	section := append(append([]byte{}, ps[0].Payload...), ps[1].Payload...)
+	assert.Equal(t, []byte{0x0, 0xfc, 0x31, 0x30}, section[:4])
+	assert.Equal(t, data, section[4:304])
+	assert.Equal(t, uint32(0), computeCRC32(section[1:308]))
+	assert.Equal(t, bytes.Repeat([]byte{0xff}, len(section)-308), section[308:])
+
+	// Second section
+	assert.True(t, ps[2].Header.PayloadUnitStartIndicator)
+	assert.Equal(t, uint8(2), ps[2].Header.ContinuityCounter)
+	assert.Equal(t, []byte{0x0, 0xfc, 0x30, 0x04, 't', 'e', 's', 't', 0xff}, ps[2].Payload[:9])
+
+	// TDT
+	assert.Equal(t, PIDTDT, ps[3].Header.PID)
+	assert.Equal(t, uint8(0), ps[3].Header.ContinuityCounter)
+	assert.Equal(t, append([]byte{0x0, 0x70, 0x70, 0x05}, dvbTimeBytes...), ps[3].Payload[:9])
+}
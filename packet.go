@@ -16,8 +16,10 @@ const (
 
 const (
 	MpegTsPacketSize       = 188
+	m2tsPacketSize         = MpegTsPacketSize + tpExtraHeaderSize
 	mpegTsPacketHeaderSize = 3
 	pcrBytesSize           = 6
+	tpExtraHeaderSize      = 4
 )
 
 var errSkippedPacket = errors.New("astits: skipped packet")
@@ -25,9 +27,17 @@ var errSkippedPacket = errors.New("astits: skipped packet")
 // Packet represents a packet
 // https://en.wikipedia.org/wiki/MPEG_transport_stream
 type Packet struct {
-	AdaptationField *PacketAdaptationField
-	Header          PacketHeader
-	Payload         []byte // This is only the payload content
+	AdaptationField   *PacketAdaptationField
+	Header            PacketHeader
+	Payload           []byte               // This is only the payload content
+	ReedSolomonParity []byte               // Only set for 204-byte packets
+	TPExtraHeader     *PacketTPExtraHeader // Only set for 192-byte packets
+}
+
+// PacketTPExtraHeader represents the header preceding each packet in 192-byte M2TS streams (Blu-ray, AVCHD)
+type PacketTPExtraHeader struct {
+	ArrivalTimeStamp        uint32 // 30 bits timestamp, in 27MHz units, at which the packet arrives in the T-STD
+	CopyPermissionIndicator uint8  // 2 bits
 }
 
 // PacketHeader represents a packet header
@@ -77,7 +87,20 @@ type PacketAdaptationExtensionField struct {
 }
 
 // parsePacket parses a packet
+// 192-byte packets are preceded by a TP_extra_header and 204-byte packets are followed by Reed-Solomon parity bytes.
+// Bytes following the first 188 bytes of packets of any other size are ignored.
 func parsePacket(i *astikit.BytesIterator, s PacketSkipper) (p *Packet, err error) {
+	// Create packet
+	p = &Packet{}
+
+	// Parse TP extra header
+	if i.Len() == m2tsPacketSize {
+		if p.TPExtraHeader, err = parsePacketTPExtraHeader(i); err != nil {
+			err = fmt.Errorf("astits: parsing packet TP extra header failed: %w", err)
+			return
+		}
+	}
+
 	// Get next byte
 	var b byte
 	if b, err = i.NextByte(); err != nil {
@@ -90,13 +113,8 @@ func parsePacket(i *astikit.BytesIterator, s PacketSkipper) (p *Packet, err erro
 		err = ErrPacketMustStartWithASyncByte
 		return
 	}
-
-	// Create packet
-	p = &Packet{}
-
-	// In case packet size is bigger than 188 bytes, we don't care for the first bytes
-	i.Seek(i.Len() - MpegTsPacketSize + 1)
 	offsetStart := i.Offset()
+	offsetEnd := offsetStart - 1 + MpegTsPacketSize
 
 	// Parse header
 	if p.Header, err = parsePacketHeader(i); err != nil {
@@ -119,8 +137,39 @@ func parsePacket(i *astikit.BytesIterator, s PacketSkipper) (p *Packet, err erro
 
 	// Build payload
 	if p.Header.HasPayload {
-		i.Seek(payloadOffset(offsetStart, p.Header, p.AdaptationField))
-		p.Payload = i.Dump()
+		if o := payloadOffset(offsetStart, p.Header, p.AdaptationField); o < offsetEnd {
+			i.Seek(o)
+			if p.Payload, err = i.NextBytes(offsetEnd - o); err != nil {
+				err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+				return
+			}
+		}
+	}
+
+	// Get Reed-Solomon parity bytes
+	if i.Len() == reedSolomonPacketSize {
+		i.Seek(offsetEnd)
+		if p.ReedSolomonParity, err = i.NextBytes(reedSolomonParitySize); err != nil {
+			err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+			return
+		}
+	}
+	return
+}
+
+// parsePacketTPExtraHeader parses the TP extra header preceding 192-byte packets
+func parsePacketTPExtraHeader(i *astikit.BytesIterator) (h *PacketTPExtraHeader, err error) {
+	// Get next bytes
+	var bs []byte
+	if bs, err = i.NextBytesNoCopy(tpExtraHeaderSize); err != nil {
+		err = fmt.Errorf("astits: fetching next bytes failed: %w", err)
+		return
+	}
+
+	// Create header
+	h = &PacketTPExtraHeader{
+		ArrivalTimeStamp:        uint32(bs[0]&0x3f)<<24 | uint32(bs[1])<<16 | uint32(bs[2])<<8 | uint32(bs[3]),
+		CopyPermissionIndicator: bs[0] >> 6,
 	}
 	return
 }
@@ -368,6 +417,15 @@ func writePacket(w *astikit.BitsWriter, p *Packet, targetPacketSize int) (writte
 	return written, nil
 }
 
+func writePacketTPExtraHeader(w *astikit.BitsWriter, h *PacketTPExtraHeader) (written int, retErr error) {
+	b := astikit.NewBitsWriterBatch(w)
+
+	b.WriteN(h.CopyPermissionIndicator, 2)
+	b.WriteN(h.ArrivalTimeStamp, 30)
+
+	return tpExtraHeaderSize, b.Err()
+}
+
 func writePacketHeader(w *astikit.BitsWriter, h PacketHeader) (written int, retErr error) {
 	b := astikit.NewBitsWriterBatch(w)
 
@@ -2,6 +2,7 @@ package astits
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -9,97 +10,147 @@ import (
 	"github.com/asticode/go-astikit"
 )
 
+// Default number of consecutive sync bytes needed to acquire lock
+// http://www.etsi.org/deliver/etsi_tr/101200_101299/101290/01.04.01_60/tr_101290v010401p.pdf (5.2.1 TS_sync_loss)
+const defaultSyncLockPackets = 5
+
+// Default number of consecutive corrupted sync bytes after which lock is lost
+// http://www.etsi.org/deliver/etsi_tr/101200_101299/101290/01.04.01_60/tr_101290v010401p.pdf (5.2.1 TS_sync_loss)
+const defaultSyncLossPackets = 2
+
+// Packet sizes that can be auto detected
+var detectablePacketSizes = []int{MpegTsPacketSize, m2tsPacketSize, reedSolomonPacketSize}
+
 // packetBuffer represents a packet buffer
 type packetBuffer struct {
 	packetSize       int
+	reedSolomon      bool
 	s                PacketSkipper
 	r                io.Reader
 	packetReadBuffer []byte
+	syncOffset       int // Offset of the sync byte in the packet
+
+	lockPackets  int    // Number of consecutive sync bytes needed to acquire lock
+	locked       bool   // Whether sync bytes are expected every packetSize bytes
+	lookahead    []byte // Bytes that have been read but not consumed yet
+	lossPackets  int    // Number of consecutive corrupted sync bytes after which lock is lost
+	offset       int64  // Offset of the next byte to be consumed
+	packetOffset int64  // Offset of the last packet returned by next()
+	skipped      int    // Number of bytes skipped during the last call to next()
 }
 
 // newPacketBuffer creates a new packet buffer
-func newPacketBuffer(r io.Reader, packetSize int, s PacketSkipper) (pb *packetBuffer, err error) {
+// If reedSolomon is true, 204-byte packets are corrected using their parity bytes and those that can't be corrected
+// get their transport error indicator set
+func newPacketBuffer(r io.Reader, packetSize, lockPackets, lossPackets int, reedSolomon bool, s PacketSkipper) (pb *packetBuffer, err error) {
 	// Init
 	pb = &packetBuffer{
-		packetSize: packetSize,
-		s:          s,
-		r:          r,
+		lockPackets: lockPackets,
+		lossPackets: lossPackets,
+		// The stream is assumed to start with a packet. If it doesn't, lock is lost on the first packet
+		// and the sync byte is hunted for
+		locked:      true,
+		packetSize:  packetSize,
+		reedSolomon: reedSolomon,
+		s:           s,
+		r:           r,
+	}
+
+	// Lock packets is not set
+	if pb.lockPackets <= 0 {
+		pb.lockPackets = defaultSyncLockPackets
+	}
+
+	// Loss packets is not set
+	if pb.lossPackets <= 0 {
+		pb.lossPackets = defaultSyncLossPackets
 	}
 
 	// Packet size is not set
 	if pb.packetSize == 0 {
 		// Auto detect packet size
-		if pb.packetSize, err = autoDetectPacketSize(r); err != nil {
+		if pb.packetSize, pb.lookahead, err = autoDetectPacketSize(r); err != nil {
 			err = fmt.Errorf("astits: auto detecting packet size failed: %w", err)
 			return
 		}
 	}
+
+	// 192-byte packets start with a TP extra header
+	if pb.packetSize == m2tsPacketSize {
+		pb.syncOffset = tpExtraHeaderSize
+	}
 	return
 }
 
 // autoDetectPacketSize updates the packet size based on the first bytes
-// Minimum packet size is 188 and is bounded by 2 sync bytes
-// Assumption is made that the first byte of the reader is a sync byte
-func autoDetectPacketSize(r io.Reader) (packetSize int, err error) {
+// Packet size is the distance between the first 2 sync bytes that are a valid packet size apart, which means
+// garbage bytes at the beginning of the reader, as well as the TP extra header of 192-byte packets, are tolerated.
+// When the reader can neither be peeked nor rewound, the bytes that have been read are returned so that
+// no packet is lost.
+func autoDetectPacketSize(r io.Reader) (packetSize int, read []byte, err error) {
 	// Read first bytes
-	const l = 193
+	const l = MpegTsPacketSize + 204 + 1
 	var b = make([]byte, l)
-	shouldRewind, rerr := peek(r, b)
+	n, shouldRewind, rerr := peek(r, b)
 	if rerr != nil {
 		err = fmt.Errorf("astits: reading first %d bytes failed: %w", l, rerr)
 		return
 	}
-
-	// Packet must start with a sync byte
-	if b[0] != syncByte {
-		err = ErrPacketMustStartWithASyncByte
-		return
-	}
+	b = b[:n]
 
 	// Look for sync bytes
-	for idx, b := range b {
-		if b == syncByte && idx >= MpegTsPacketSize {
+	for start := range b {
+		if b[start] != syncByte {
+			continue
+		}
+		for _, s := range detectablePacketSizes {
+			if start+s >= len(b) || b[start+s] != syncByte {
+				continue
+			}
+
 			// Update packet size
-			packetSize = idx
+			packetSize = s
 
 			if !shouldRewind {
 				return
 			}
 
-			// Rewind or sync reader
+			// Rewind reader
 			var n int64
 			if n, err = rewind(r); err != nil {
 				err = fmt.Errorf("astits: rewinding failed: %w", err)
 				return
 			} else if n == -1 {
-				var ls = packetSize - (l - packetSize)
-				if _, err = r.Read(make([]byte, ls)); err != nil {
-					err = fmt.Errorf("astits: reading %d bytes to sync reader failed: %w", ls, err)
-					return
-				}
+				read = b
 			}
 			return
 		}
 	}
-	err = fmt.Errorf("astits: only one sync byte detected in first %d bytes", l)
+	err = fmt.Errorf("astits: no sync bytes a valid packet size apart detected in first %d bytes", n)
 	return
 }
 
 // bufio.Reader can't be rewinded, which leads to packet loss on packet size autodetection
 // but it has handy Peek() method
 // so what we do here is peeking bytes for bufio.Reader and falling back to rewinding/syncing for all other readers
-func peek(r io.Reader, b []byte) (shouldRewind bool, err error) {
-	if br, ok := r.(*bufio.Reader); ok {
+func peek(r io.Reader, b []byte) (n int, shouldRewind bool, err error) {
+	var br interface{ Peek(n int) ([]byte, error) }
+	switch v := r.(type) {
+	case *bufio.Reader:
+		br = v
+	case *ctxPeekReader:
+		br = v
+	}
+	if br != nil {
 		var bs []byte
 		bs, err = br.Peek(len(b))
-		if err != nil {
+		if err != nil && (err != io.EOF || len(bs) == 0) {
 			return
 		}
-		copy(b, bs)
-		return false, nil
+		return copy(b, bs), false, nil
 	}
 
-	_, err = r.Read(b)
+	n, err = r.Read(b)
 	shouldRewind = true
 	return
 }
@@ -124,9 +175,19 @@ func (pb *packetBuffer) next() (p *Packet, err error) {
 		pb.packetReadBuffer = make([]byte, pb.packetSize)
 	}
 
+	// Reset skipped bytes
+	pb.skipped = 0
+
 	// Loop to make sure we return a packet even if first packets are skipped
 	for p == nil {
-		if _, err = io.ReadFull(pb.r, pb.packetReadBuffer); err != nil {
+		// Hunt for the sync byte
+		if !pb.locked {
+			if err = pb.lock(); err != nil {
+				return
+			}
+		}
+
+		if err = pb.read(pb.packetReadBuffer); err != nil {
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
 				err = ErrNoMorePackets
 			} else {
@@ -135,6 +196,35 @@ func (pb *packetBuffer) next() (p *Packet, err error) {
 			return
 		}
 
+		// Sync byte is corrupted
+		if pb.packetReadBuffer[pb.syncOffset] != syncByte {
+			var lost bool
+			if lost, err = pb.syncLost(); err != nil {
+				return
+			}
+
+			// Sync byte has disappeared, we drop out of lock and hunt for it starting with the next byte
+			if lost {
+				pb.locked = false
+				pb.lookahead = append(append([]byte{}, pb.packetReadBuffer[1:]...), pb.lookahead...)
+				pb.offset -= int64(pb.packetSize - 1)
+				pb.skipped++
+				continue
+			}
+
+			// Only the sync byte is corrupted, we keep the packet
+			pb.packetReadBuffer[pb.syncOffset] = syncByte
+		}
+		pb.packetOffset = pb.offset - int64(pb.packetSize)
+
+		// Correct packet
+		if pb.reedSolomon && pb.packetSize == reedSolomonPacketSize {
+			if _, ok := correctReedSolomon(pb.packetReadBuffer); !ok {
+				// Set the transport error indicator
+				pb.packetReadBuffer[1] |= 0x80
+			}
+		}
+
 		// Parse packet
 		if p, err = parsePacket(astikit.NewBytesIterator(pb.packetReadBuffer), pb.s); err != nil {
 			if !errors.Is(err, errSkippedPacket) {
@@ -146,3 +236,136 @@ func (pb *packetBuffer) next() (p *Packet, err error) {
 
 	return
 }
+
+// read fills b with lookahead bytes first and with bytes read from the reader then
+func (pb *packetBuffer) read(b []byte) (err error) {
+	n := copy(b, pb.lookahead)
+	pb.lookahead = pb.lookahead[n:]
+	if n < len(b) {
+		if _, err = io.ReadFull(pb.r, b[n:]); err != nil {
+			return
+		}
+	}
+	pb.offset += int64(len(b))
+	return
+}
+
+// fill makes sure the lookahead contains at least n bytes unless the end of the reader has been reached
+func (pb *packetBuffer) fill(n int) (eof bool, err error) {
+	// Lookahead is big enough
+	if len(pb.lookahead) >= n {
+		return
+	}
+
+	// Read missing bytes
+	b := make([]byte, n-len(pb.lookahead))
+	var m int
+	m, err = io.ReadFull(pb.r, b)
+	pb.lookahead = append(pb.lookahead, b[:m]...)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		eof = true
+		err = nil
+	} else if err != nil {
+		err = fmt.Errorf("astits: reading %d bytes failed: %w", len(b), err)
+	}
+	return
+}
+
+// skip skips the n first bytes of the lookahead
+func (pb *packetBuffer) skip(n int) {
+	pb.lookahead = pb.lookahead[n:]
+	pb.offset += int64(n)
+	pb.skipped += n
+}
+
+// syncLost checks whether lock is lost after the sync byte of the packet that has just been read turned out to be
+// corrupted, which is the case when the sync bytes of the lossPackets-1 following packets are corrupted as well. If
+// the end of the reader is reached before a sync byte in place is found, lock is lost.
+func (pb *packetBuffer) syncLost() (lost bool, err error) {
+	// Number of bytes needed to check the following sync bytes
+	l := pb.syncOffset + (pb.lossPackets-2)*pb.packetSize + 1
+	if l <= pb.syncOffset {
+		return true, nil
+	}
+
+	// Fill lookahead
+	if _, err = pb.fill(l); err != nil {
+		return
+	}
+
+	// Check following sync bytes
+	for o := pb.syncOffset; o < len(pb.lookahead) && o < l; o += pb.packetSize {
+		if pb.lookahead[o] == syncByte {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lock hunts for a sync byte repeated every packetSize bytes over lockPackets packets
+// Bytes preceding it are skipped. If the end of the reader is reached before lockPackets packets
+// have been checked, lock is acquired as long as every sync byte available is in place.
+func (pb *packetBuffer) lock() (err error) {
+	// Number of bytes needed to check every sync byte
+	l := pb.syncOffset + (pb.lockPackets-1)*pb.packetSize + 1
+
+	for {
+		// Fill lookahead
+		var eof bool
+		if eof, err = pb.fill(l); err != nil {
+			return
+		}
+
+		// Look for a sync byte
+		var idx int
+		if len(pb.lookahead) > pb.syncOffset {
+			idx = bytes.IndexByte(pb.lookahead[pb.syncOffset:], syncByte)
+		} else {
+			idx = -1
+		}
+		if idx < 0 {
+			// Bytes that may precede the next sync byte are kept
+			n := len(pb.lookahead) - pb.syncOffset
+			if eof || n < 0 {
+				n = len(pb.lookahead)
+			}
+			pb.skip(n)
+			if eof {
+				return ErrNoMorePackets
+			}
+			continue
+		}
+
+		// Skip bytes preceding the packet
+		pb.skip(idx)
+		if idx > 0 {
+			if eof, err = pb.fill(l); err != nil {
+				return
+			}
+		}
+
+		// Not enough bytes left for a packet
+		if eof && len(pb.lookahead) < pb.packetSize {
+			pb.skip(len(pb.lookahead))
+			return ErrNoMorePackets
+		}
+
+		// Check following sync bytes
+		locked := true
+		for o := pb.syncOffset + pb.packetSize; o < len(pb.lookahead) && o < l; o += pb.packetSize {
+			if pb.lookahead[o] != syncByte {
+				locked = false
+				break
+			}
+		}
+
+		// Lock is acquired
+		if locked {
+			pb.locked = true
+			return
+		}
+
+		// Sync byte was a false positive
+		pb.skip(1)
+	}
+}
@@ -9,13 +9,13 @@ import (
 )
 
 func TestAutoDetectPacketSize(t *testing.T) {
-	// Packet should start with a sync byte
+	// Only one sync byte
 	buf := &bytes.Buffer{}
 	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
 	w.Write(uint8(2))
 	w.Write(byte(syncByte))
-	_, err := autoDetectPacketSize(bytes.NewReader(buf.Bytes()))
-	assert.EqualError(t, err, ErrPacketMustStartWithASyncByte.Error())
+	_, _, err := autoDetectPacketSize(bytes.NewReader(buf.Bytes()))
+	assert.EqualError(t, err, "astits: no sync bytes a valid packet size apart detected in first 2 bytes")
 
 	// Valid packet size
 	buf.Reset()
@@ -27,8 +27,152 @@ func TestAutoDetectPacketSize(t *testing.T) {
 	w.Write(make([]byte, 187))
 	w.Write([]byte("test"))
 	r := bytes.NewReader(buf.Bytes())
-	p, err := autoDetectPacketSize(r)
+	p, read, err := autoDetectPacketSize(r)
 	assert.NoError(t, err)
 	assert.Equal(t, MpegTsPacketSize, p)
+	assert.Nil(t, read)
 	assert.Equal(t, 380, r.Len())
+
+	// Garbage bytes before the first sync byte
+	buf.Reset()
+	w.Write([]byte{0x1, 0x2, 0x3})
+	w.Write(byte(syncByte))
+	w.Write(make([]byte, 191))
+	w.Write(byte(syncByte))
+	w.Write(make([]byte, 191))
+	p, read, err = autoDetectPacketSize(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, 192, p)
+	assert.Nil(t, read)
+
+	// Reader can't be rewound
+	p, read, err = autoDetectPacketSize(bytes.NewBuffer(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, 192, p)
+	assert.Equal(t, buf.Bytes(), read)
+}
+
+func TestPacketBufferResync(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write([]byte{0x1, syncByte, 0x2}) // Garbage with a false sync byte
+	for cc := 0; cc < 8; cc++ {
+		if cc == 3 {
+			w.Write([]byte{0x3, 0x4}) // Glitch
+		}
+		b, _ := packetShort(PacketHeader{ContinuityCounter: uint8(cc), HasPayload: true, PID: 1}, nil)
+		w.Write(b)
+	}
+
+	pb, err := newPacketBuffer(bytes.NewReader(buf.Bytes()), MpegTsPacketSize, 3, 0, false, nil)
+	assert.NoError(t, err)
+	var ccs []uint8
+	var skipped []int
+	for {
+		p, err := pb.next()
+		if err == ErrNoMorePackets {
+			break
+		}
+		assert.NoError(t, err)
+		ccs = append(ccs, p.Header.ContinuityCounter)
+		skipped = append(skipped, pb.skipped)
+	}
+	assert.Equal(t, []uint8{0, 1, 2, 3, 4, 5, 6, 7}, ccs)
+	assert.Equal(t, []int{3, 0, 0, 2, 0, 0, 0, 0}, skipped)
+}
+
+func TestPacketBufferSyncLoss(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	for cc := 0; cc < 10; cc++ {
+		b, _ := packetShort(PacketHeader{ContinuityCounter: uint8(cc), HasPayload: true, PID: 1}, nil)
+		switch cc {
+		case 2, 5, 6:
+			// Corrupted sync byte
+			b[0] = 0x0
+		}
+		w.Write(b)
+	}
+
+	for _, v := range []struct {
+		ccs         []uint8
+		lossPackets int
+	}{
+		{ccs: []uint8{0, 1, 2, 3, 4, 7, 8, 9}},
+		{ccs: []uint8{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, lossPackets: 3},
+		{ccs: []uint8{0, 1, 7, 8, 9}, lossPackets: 1},
+	} {
+		pb, err := newPacketBuffer(bytes.NewReader(buf.Bytes()), MpegTsPacketSize, 3, v.lossPackets, false, nil)
+		assert.NoError(t, err)
+		var ccs []uint8
+		for {
+			p, err := pb.next()
+			if err == ErrNoMorePackets {
+				break
+			}
+			assert.NoError(t, err)
+			ccs = append(ccs, p.Header.ContinuityCounter)
+		}
+		assert.Equal(t, v.ccs, ccs)
+	}
+}
+
+func TestPacketBufferM2TS(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write([]byte{0x1, 0x2}) // Garbage
+	for cc := 0; cc < 3; cc++ {
+		w.Write(tpExtraHeaderBytes())
+		b, _ := packetShort(PacketHeader{ContinuityCounter: uint8(cc), HasPayload: true, PID: 1}, nil)
+		w.Write(b)
+	}
+
+	pb, err := newPacketBuffer(bytes.NewReader(buf.Bytes()), 0, 0, 0, false, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, m2tsPacketSize, pb.packetSize)
+	for cc := 0; cc < 3; cc++ {
+		p, err := pb.next()
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(cc), p.Header.ContinuityCounter)
+		assert.Equal(t, packetTPExtraHeader, p.TPExtraHeader)
+		assert.Equal(t, int64(2+cc*m2tsPacketSize), pb.packetOffset)
+	}
+	_, err = pb.next()
+	assert.Equal(t, ErrNoMorePackets, err)
+}
+
+func TestPacketBufferReedSolomon(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	for cc := 0; cc < 3; cc++ {
+		b, _ := packetShort(PacketHeader{ContinuityCounter: uint8(cc), HasPayload: true, PID: 1}, nil)
+		parity := computeReedSolomonParity(b)
+		switch cc {
+		case 1:
+			// Correctable
+			b[10] ^= 0xff
+		case 2:
+			// Uncorrectable
+			for i := 10; i < 30; i++ {
+				b[i] ^= byte(i)
+			}
+		}
+		w.Write(b)
+		w.Write(parity)
+	}
+
+	for _, reedSolomon := range []bool{false, true} {
+		pb, err := newPacketBuffer(bytes.NewReader(buf.Bytes()), 0, 0, 0, reedSolomon, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, reedSolomonPacketSize, pb.packetSize)
+		for cc := 0; cc < 3; cc++ {
+			p, err := pb.next()
+			assert.NoError(t, err)
+			assert.Len(t, p.ReedSolomonParity, reedSolomonParitySize)
+			assert.Equal(t, reedSolomon && cc == 2, p.Header.TransportErrorIndicator)
+			if cc == 1 {
+				assert.Equal(t, reedSolomon, p.Payload[6] == 0)
+			}
+		}
+	}
 }
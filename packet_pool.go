@@ -4,6 +4,10 @@ import (
 	"sort"
 )
 
+// Max number of bytes the queue of a PSI PID may hold once complete sections have been flushed, which is the pointer
+// field, its filler bytes and the max size of a section
+const psiQueueMaxLength = 1 + 255 + 4096
+
 // packetAccumulator keeps track of packets for a single PID and decides when to flush them
 type packetAccumulator struct {
 	pid        uint16
@@ -38,6 +42,11 @@ func (b *packetAccumulator) add(p *Packet) (ps []*Packet) {
 		return
 	}
 
+	// PSI sections are accumulated until they are complete
+	if isPSIPayload(b.pid, b.programMap) {
+		return b.addPSI(p, mps)
+	}
+
 	// Flush buffer if new payload starts here
 	if p.Header.PayloadUnitStartIndicator {
 		ps = mps
@@ -46,10 +55,9 @@ func (b *packetAccumulator) add(p *Packet) (ps []*Packet) {
 
 	mps = append(mps, p)
 
-	// Check if PSI payload is complete
-	if b.programMap != nil &&
-		(b.pid == PIDPAT || b.programMap.existsUnlocked(b.pid)) &&
-		isPSIComplete(mps) {
+	// Flush buffer if the PES packet length is known and has been reached so that we don't have to wait for the
+	// next payload to start. If the buffer has just been flushed, this will happen when the next packet arrives.
+	if len(ps) == 0 && isPESComplete(mps) {
 		ps = mps
 		mps = nil
 	}
@@ -58,6 +66,84 @@ func (b *packetAccumulator) add(p *Packet) (ps []*Packet) {
 	return
 }
 
+// addPSI adds a new PSI packet for this PID to the queue
+// PSI sections don't have to start at the beginning of a payload: when the payload unit start indicator is set, bytes
+// between the pointer field and the first section starting in the packet are the tail of the section in progress.
+// Therefore, instead of being flushed, the queue keeps on accumulating the sections, and sections are flushed as soon as
+// they are complete: on tightly packed streams, a packet ending a section often starts the next one. Only the section
+// in progress is kept in the queue.
+func (b *packetAccumulator) addPSI(p *Packet, mps []*Packet) (ps []*Packet) {
+	if len(mps) == 0 {
+		// The start of the section is unknown
+		if !p.Header.PayloadUnitStartIndicator {
+			b.q = mps
+			return
+		}
+	} else if p.Header.PayloadUnitStartIndicator && len(p.Payload) > 0 && p.Payload[0] == 0 {
+		// There's no tail which means the section in progress has been truncated
+		mps = mps[:0]
+	} else if p.Header.PayloadUnitStartIndicator && len(p.Payload) > 0 {
+		// Remove the pointer field so that the tail and the sections starting in the packet follow
+		// the section in progress
+		c := *p
+		c.Header.PayloadUnitStartIndicator = false
+		c.Payload = p.Payload[1:]
+		p = &c
+	}
+
+	mps = append(mps, p)
+
+	// Flush complete sections
+	n, complete := psiCompleteLength(mps)
+	switch {
+	case complete:
+		ps = mps
+		mps = nil
+	case n > 0:
+		ps, mps = splitPSIPackets(mps, n)
+	case payloadLength(mps) > psiQueueMaxLength:
+		// The section in progress can't be that long
+		mps = nil
+	}
+
+	b.q = mps
+	return
+}
+
+// splitPSIPackets splits a PSI payload made of packets after its n first bytes. Packets of the second half start
+// with a copy of the packet the split happens in, with a pointer field, so that they can be parsed on their own.
+func splitPSIPackets(ps []*Packet, n int) (head, tail []*Packet) {
+	var o int
+	for idx, p := range ps {
+		// Split doesn't happen in this packet
+		if o+len(p.Payload) < n {
+			o += len(p.Payload)
+			continue
+		}
+
+		// Head
+		h := *p
+		h.Payload = p.Payload[:n-o]
+		head = append(append(make([]*Packet, 0, idx+1), ps[:idx]...), &h)
+
+		// Tail
+		t := *p
+		t.Header.PayloadUnitStartIndicator = true
+		t.Payload = append([]byte{0}, p.Payload[n-o:]...)
+		tail = append([]*Packet{&t}, ps[idx+1:]...)
+		return
+	}
+	return ps, nil
+}
+
+// payloadLength returns the length of the payload made of the packets
+func payloadLength(ps []*Packet) (l int) {
+	for _, p := range ps {
+		l += len(p.Payload)
+	}
+	return
+}
+
 // packetPool represents a queue of packets for each PID in the stream
 type packetPool struct {
 	// We use map[uint32] instead map[uint16] as go runtime provide optimized hash functions for (u)int32/64 keys
@@ -82,8 +168,8 @@ func (b *packetPool) addUnlocked(p *Packet) (ps []*Packet) {
 		return
 	}
 
-	// Throw away packets that don't have a payload until we figure out what we're going to do with them
-	// TODO figure out what we're going to do with them :D
+	// Throw away packets that don't have a payload since they don't contribute to any payload
+	// Their adaptation field can be retrieved with DemuxerOptAdaptationFieldData
 	if !p.Header.HasPayload {
 		return
 	}
@@ -1,6 +1,7 @@
 package astits
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -22,28 +23,142 @@ func TestIsSameAsPrevious(t *testing.T) {
 
 func TestPacketPool(t *testing.T) {
 	b := newPacketPool(nil)
-	ps := b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 0, HasPayload: true, PID: 1}})
+	ps := b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 0, HasPayload: true, PID: 0x100}})
 	assert.Len(t, ps, 0)
-	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 1, HasPayload: true, PayloadUnitStartIndicator: true, PID: 1}})
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 1, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x100}})
 	assert.Len(t, ps, 1)
-	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 1, HasPayload: true, PayloadUnitStartIndicator: true, PID: 2}})
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 1, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x101}})
 	assert.Len(t, ps, 0)
-	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 2, HasPayload: true, PID: 1}})
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 2, HasPayload: true, PID: 0x100}})
 	assert.Len(t, ps, 0)
-	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 3, HasPayload: true, PayloadUnitStartIndicator: true, PID: 1}})
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 3, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x100}})
 	assert.Len(t, ps, 2)
-	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 5, HasPayload: true, PID: 1}})
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 5, HasPayload: true, PID: 0x100}})
 	assert.Len(t, ps, 0)
-	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 6, HasPayload: true, PayloadUnitStartIndicator: true, PID: 1}})
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 6, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x100}})
 	assert.Len(t, ps, 1)
-	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 7, HasPayload: true, PID: 1}})
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 7, HasPayload: true, PID: 0x100}})
 	assert.Len(t, ps, 0)
 	ps = b.dumpUnlocked()
 	assert.Len(t, ps, 2)
-	assert.Equal(t, uint16(1), ps[0].Header.PID)
+	assert.Equal(t, uint16(0x100), ps[0].Header.PID)
 	ps = b.dumpUnlocked()
 	assert.Len(t, ps, 1)
-	assert.Equal(t, uint16(2), ps[0].Header.PID)
+	assert.Equal(t, uint16(0x101), ps[0].Header.PID)
 	ps = b.dumpUnlocked()
 	assert.Len(t, ps, 0)
 }
+
+func TestPacketPoolPSISectionTail(t *testing.T) {
+	b := newPacketPool(nil)
+
+	// Start of the section is unknown
+	ps := b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 0, HasPayload: true, PID: 0x11}, Payload: []byte{1, 2}})
+	assert.Len(t, ps, 0)
+
+	// Section ends in the next packet
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 1, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x11}, Payload: []byte{0, 0x42, 0xb0, 0x5, 1, 2}})
+	assert.Len(t, ps, 0)
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 2, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x11}, Payload: []byte{3, 3, 4, 5, 0x42, 0xb0, 0x5, 1, 2, 3, 4, 5, 0xff}})
+	assert.Len(t, ps, 2)
+	assert.Equal(t, []byte{0, 0x42, 0xb0, 0x5, 1, 2}, ps[0].Payload)
+	assert.Equal(t, []byte{3, 4, 5, 0x42, 0xb0, 0x5, 1, 2, 3, 4, 5, 0xff}, ps[1].Payload)
+	assert.False(t, ps[1].Header.PayloadUnitStartIndicator)
+
+	// Section in progress has been truncated
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 3, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x11}, Payload: []byte{0, 0x42, 0xb0, 0x5, 1, 2}})
+	assert.Len(t, ps, 0)
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 4, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x11}, Payload: []byte{0, 0x42, 0xb0, 0x5, 1, 2, 3, 4, 5, 0xff}})
+	assert.Len(t, ps, 1)
+	assert.Equal(t, []byte{0, 0x42, 0xb0, 0x5, 1, 2, 3, 4, 5, 0xff}, ps[0].Payload)
+}
+
+func TestPacketPoolPSIBackToBackSections(t *testing.T) {
+	// Sections follow each other without stuffing bytes
+	var sections [][]byte
+	var stream []byte
+	var starts = make(map[int]bool)
+	for idx := 0; idx < 5; idx++ {
+		s := append([]byte{0x42, 0xb0, 0xc8}, bytes.Repeat([]byte{byte(idx)}, 200)...)
+		sections = append(sections, s)
+		starts[len(stream)] = true
+		stream = append(stream, s...)
+	}
+
+	// Packetize sections
+	var pkts []*Packet
+	for o, cc := 0, 0; o < len(stream); cc++ {
+		p := &Packet{Header: PacketHeader{ContinuityCounter: uint8(cc % 16), HasPayload: true, PID: 0x12}}
+		l := 184
+		for so := o; so < o+183 && so < len(stream); so++ {
+			if starts[so] {
+				p.Header.PayloadUnitStartIndicator = true
+				p.Payload = []byte{byte(so - o)}
+				l = 183
+				break
+			}
+		}
+		if o+l > len(stream) {
+			l = len(stream) - o
+		}
+		p.Payload = append(p.Payload, stream[o:o+l]...)
+		o += l
+		pkts = append(pkts, p)
+	}
+
+	// Sections are flushed as soon as they're complete
+	b := newPacketPool(nil)
+	var got [][]byte
+	var flushes int
+	for _, p := range pkts {
+		ps := b.addUnlocked(p)
+		assert.True(t, payloadLength(b.b[0x12].q) <= psiQueueMaxLength)
+		if len(ps) == 0 {
+			continue
+		}
+		flushes++
+		var payload []byte
+		for _, p := range ps {
+			payload = append(payload, p.Payload...)
+		}
+		payload = payload[1+int(payload[0]):]
+		for len(payload) > 0 {
+			l := 3 + int(payload[1]&0xf)<<8 + int(payload[2])
+			got = append(got, payload[:l])
+			payload = payload[l:]
+		}
+	}
+	assert.Equal(t, sections, got)
+	assert.Equal(t, 5, flushes)
+
+	// Queue is capped
+	b = newPacketPool(nil)
+	b.addUnlocked(&Packet{Header: PacketHeader{HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x12}, Payload: append([]byte{0, 0x42, 0xbf, 0xff}, make([]byte, 180)...)})
+	for cc := 1; cc < 30; cc++ {
+		b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: uint8(cc % 16), HasPayload: true, PID: 0x12}, Payload: make([]byte, 184)})
+		assert.True(t, payloadLength(b.b[0x12].q) <= psiQueueMaxLength)
+	}
+}
+
+func TestPacketPoolPESPacketLength(t *testing.T) {
+	b := newPacketPool(nil)
+
+	// Known PES packet length
+	ps := b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 0, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x100}, Payload: []byte{0, 0, 1, 0xc0, 0, 3, 1, 2}})
+	assert.Len(t, ps, 0)
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 1, HasPayload: true, PID: 0x100}, Payload: []byte{3}})
+	assert.Len(t, ps, 2)
+
+	// Unknown PES packet length
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 2, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x100}, Payload: []byte{0, 0, 1, 0xe0, 0, 0, 1, 2}})
+	assert.Len(t, ps, 0)
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 3, HasPayload: true, PID: 0x100}, Payload: []byte{3}})
+	assert.Len(t, ps, 0)
+
+	// Complete PES packet following a flushed one
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 4, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x100}, Payload: []byte{0, 0, 1, 0xc0, 0, 1, 1}})
+	assert.Len(t, ps, 2)
+	ps = b.addUnlocked(&Packet{Header: PacketHeader{ContinuityCounter: 5, HasPayload: true, PayloadUnitStartIndicator: true, PID: 0x100}, Payload: []byte{0, 0, 1, 0xc0, 0, 1, 1}})
+	assert.Len(t, ps, 1)
+	assert.Equal(t, uint8(4), ps[0].Header.ContinuityCounter)
+}
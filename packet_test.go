@@ -12,10 +12,12 @@ import (
 func packet(h PacketHeader, a PacketAdaptationField, i []byte, packet192bytes bool) ([]byte, *Packet) {
 	buf := &bytes.Buffer{}
 	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
-	w.Write(uint8(syncByte)) // Sync byte
+	var tpExtraHeader *PacketTPExtraHeader
 	if packet192bytes {
-		w.Write([]byte("test")) // Sometimes packets are 192 bytes
+		w.Write(tpExtraHeaderBytes()) // Sometimes packets are 192 bytes
+		tpExtraHeader = packetTPExtraHeader
 	}
+	w.Write(uint8(syncByte))                                        // Sync byte
 	w.Write(packetHeaderBytes(h, "11"))                             // Header
 	w.Write(packetAdaptationFieldBytes(a))                          // Adaptation field
 	var payload = append(i, bytes.Repeat([]byte{0}, 147-len(i))...) // Payload
@@ -24,9 +26,23 @@ func packet(h PacketHeader, a PacketAdaptationField, i []byte, packet192bytes bo
 		AdaptationField: packetAdaptationField,
 		Header:          packetHeader,
 		Payload:         payload,
+		TPExtraHeader:   tpExtraHeader,
 	}
 }
 
+var packetTPExtraHeader = &PacketTPExtraHeader{
+	ArrivalTimeStamp:        0x2345678,
+	CopyPermissionIndicator: 2,
+}
+
+func tpExtraHeaderBytes() []byte {
+	buf := &bytes.Buffer{}
+	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
+	w.Write("10")                             // Copy permission indicator
+	w.Write("000010001101000101011001111000") // Arrival time stamp
+	return buf.Bytes()
+}
+
 func packetShort(h PacketHeader, payload []byte) ([]byte, *Packet) {
 	buf := &bytes.Buffer{}
 	w := astikit.NewBitsWriter(astikit.BitsWriterOptions{Writer: buf})
@@ -57,6 +73,13 @@ func TestParsePacket(t *testing.T) {
 	// Skip
 	_, err = parsePacket(astikit.NewBytesIterator(b), func(p *Packet) bool { return true })
 	assert.EqualError(t, err, errSkippedPacket.Error())
+
+	// 204-byte packet
+	b, ep = packet(packetHeader, *packetAdaptationField, []byte("payload"), false)
+	ep.ReedSolomonParity = computeReedSolomonParity(b)
+	p, err = parsePacket(astikit.NewBytesIterator(append(b, ep.ReedSolomonParity...)), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, p, ep)
 }
 
 func TestPayloadOffset(t *testing.T) {
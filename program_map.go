@@ -1,15 +1,19 @@
 package astits
 
+import "sort"
+
 // programMap represents a program ids map
 type programMap struct {
 	// We use map[uint32] instead map[uint16] as go runtime provide optimized hash functions for (u)int32/64 keys
 	p map[uint32]uint16 // map[ProgramMapID]ProgramNumber
+	s map[uint32]uint16 // map[SCTE35PID]ProgramNumber
 }
 
 // newProgramMap creates a new program ids map
 func newProgramMap() *programMap {
 	return &programMap{
 		p: make(map[uint32]uint16),
+		s: make(map[uint32]uint16),
 	}
 }
 
@@ -28,10 +32,44 @@ func (m programMap) unsetUnlocked(pid uint16) {
 	delete(m.p, uint32(pid))
 }
 
-func (m programMap) toPATDataUnlocked() *PATData {
+// isSCTE35Unlocked checks whether the pid carries SCTE-35 splice info sections
+func (m programMap) isSCTE35Unlocked(pid uint16) (ok bool) {
+	_, ok = m.s[uint32(pid)]
+	return
+}
+
+// setSCTE35Unlocked sets the pids carrying SCTE-35 splice info sections of a program, replacing the previous ones
+func (m programMap) setSCTE35Unlocked(number uint16, pids []uint16) {
+	for pid, n := range m.s {
+		if n == number {
+			delete(m.s, pid)
+		}
+	}
+	for _, pid := range pids {
+		m.s[uint32(pid)] = number
+	}
+}
+
+// retainSCTE35Unlocked removes the pids carrying SCTE-35 splice info sections of the programs that are not in numbers
+func (m programMap) retainSCTE35Unlocked(numbers []uint16) {
+	for pid, n := range m.s {
+		var ok bool
+		for _, number := range numbers {
+			if n == number {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			delete(m.s, pid)
+		}
+	}
+}
+
+func (m programMap) toPATDataUnlocked(transportStreamID uint16) *PATData {
 	d := &PATData{
 		Programs:          make([]*PATProgram, 0, len(m.p)),
-		TransportStreamID: uint16(PSITableIDPAT),
+		TransportStreamID: transportStreamID,
 	}
 
 	for pid, pnr := range m.p {
@@ -41,5 +79,7 @@ func (m programMap) toPATDataUnlocked() *PATData {
 		})
 	}
 
+	// Programs are sorted by program number so that the PAT is stable
+	sort.Slice(d.Programs, func(i, j int) bool { return d.Programs[i].ProgramNumber < d.Programs[j].ProgramNumber })
 	return d
 }
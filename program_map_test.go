@@ -13,4 +13,15 @@ func TestProgramMap(t *testing.T) {
 	assert.True(t, pm.existsUnlocked(1))
 	pm.unsetUnlocked(1)
 	assert.False(t, pm.existsUnlocked(1))
+
+	pm.setSCTE35Unlocked(1, []uint16{0x100, 0x101})
+	pm.setSCTE35Unlocked(2, []uint16{0x102})
+	assert.True(t, pm.isSCTE35Unlocked(0x100))
+	pm.setSCTE35Unlocked(1, []uint16{0x101})
+	assert.False(t, pm.isSCTE35Unlocked(0x100))
+	assert.True(t, pm.isSCTE35Unlocked(0x101))
+	assert.True(t, pm.isSCTE35Unlocked(0x102))
+	pm.retainSCTE35Unlocked([]uint16{2, 3})
+	assert.False(t, pm.isSCTE35Unlocked(0x101))
+	assert.True(t, pm.isSCTE35Unlocked(0x102))
 }
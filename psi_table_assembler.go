@@ -0,0 +1,247 @@
+package astits
+
+import (
+	"sort"
+	"time"
+)
+
+// Default stream duration after which an incomplete PSI table is discarded
+// EIT schedule tables are the slowest to be repeated: ETSI TS 101 211 recommends up to 10s for the first day
+const defaultPSITableAssemblyTimeout = 30 * time.Second
+
+// Bitrate, in bits per second, used to convert the timeout into a number of packets when the stream has no PCR
+// It's high so that tables of high bitrate streams don't time out too early.
+const psiTableAssemblyFallbackBitrate = 100000000
+
+// psiTableAssembler collects the sections of PSI tables split over several sections and returns
+// tables only once every section has been received
+type psiTableAssembler struct {
+	c              *streamClocks
+	tables         map[psiTableKey]*psiTable
+	timeout        int64 // In 27MHz units
+	timeoutPackets int64 // Timeout when there's no PCR
+}
+
+// psiTableKey identifies a sub table. Original network ID is only used by EIT and SDT, and transport stream ID is only
+// used by EIT.
+type psiTableKey struct {
+	originalNetworkID uint16
+	pid               uint16
+	tableID           PSITableID
+	tableIDExtension  uint16
+	transportStreamID uint16
+}
+
+// psiTable represents a table whose sections are being collected
+type psiTable struct {
+	firstPacket       *Packet
+	lastSectionNumber uint8
+	sections          map[uint8]*PSISection
+	start             int64 // Stream time of the first section, in 27MHz units
+	startPackets      int64 // Number of packets when the first section was received
+	versionNumber     uint8
+}
+
+// newPSITableAssembler creates a new PSI table assembler
+// The timeout is checked against the stream time of c so that it doesn't depend on how fast the stream is demuxed,
+// or against its number of packets when the stream has no PCR
+func newPSITableAssembler(timeout time.Duration, c *streamClocks) *psiTableAssembler {
+	if timeout <= 0 {
+		timeout = defaultPSITableAssemblyTimeout
+	}
+	return &psiTableAssembler{
+		c:              c,
+		tables:         make(map[psiTableKey]*psiTable),
+		timeout:        timeout.Nanoseconds() * 27 / 1000,
+		timeoutPackets: int64(timeout.Seconds() * psiTableAssemblyFallbackBitrate / (MpegTsPacketSize * 8)),
+	}
+}
+
+// newPSITableKey returns the key of the sub table the section belongs to
+func newPSITableKey(s *PSISection, pid uint16) (k psiTableKey) {
+	k = psiTableKey{
+		pid:     pid,
+		tableID: s.Header.TableID,
+	}
+	if s.Syntax != nil && s.Syntax.Header != nil {
+		k.tableIDExtension = s.Syntax.Header.TableIDExtension
+	}
+	if s.Syntax != nil && s.Syntax.Data != nil && s.Syntax.Data.EIT != nil {
+		k.originalNetworkID = s.Syntax.Data.EIT.OriginalNetworkID
+		k.transportStreamID = s.Syntax.Data.EIT.TransportStreamID
+	}
+	if s.Syntax != nil && s.Syntax.Data != nil && s.Syntax.Data.SDT != nil {
+		k.originalNetworkID = s.Syntax.Data.SDT.OriginalNetworkID
+	}
+	return
+}
+
+// add adds a section and returns the section holding the complete table as well as its first packet, if any
+func (a *psiTableAssembler) add(s *PSISection, firstPacket *Packet, pid uint16) (*PSISection, *Packet) {
+	// Discard tables that have timed out
+	now, packets := a.c.time(), a.c.packets
+	for k, t := range a.tables {
+		if (a.c.hasTime() && now-t.start > a.timeout) || (!a.c.hasTime() && packets-t.startPackets > a.timeoutPackets) {
+			delete(a.tables, k)
+		}
+	}
+
+	// No data
+	if s.Syntax == nil || s.Syntax.Data == nil {
+		return nil, nil
+	}
+
+	// Tables without syntax header can't be split over several sections
+	if s.Syntax.Header == nil {
+		return s, firstPacket
+	}
+
+	// Sections that are not applicable yet are dropped since they will be retransmitted with the
+	// current_next_indicator set once they become applicable
+	if !s.Syntax.Header.CurrentNextIndicator {
+		return nil, nil
+	}
+
+	// Get table. A new version of the table discards sections of the previous one
+	k := newPSITableKey(s, pid)
+	t, ok := a.tables[k]
+	if !ok || t.versionNumber != s.Syntax.Header.VersionNumber || t.lastSectionNumber != s.Syntax.Header.LastSectionNumber {
+		t = &psiTable{
+			firstPacket:       firstPacket,
+			lastSectionNumber: s.Syntax.Header.LastSectionNumber,
+			sections:          make(map[uint8]*PSISection),
+			start:             now,
+			startPackets:      packets,
+			versionNumber:     s.Syntax.Header.VersionNumber,
+		}
+		a.tables[k] = t
+	}
+
+	// Add section
+	t.sections[s.Syntax.Header.SectionNumber] = s
+
+	// Table is not complete
+	if !t.isComplete() {
+		return nil, nil
+	}
+
+	// Merge sections
+	delete(a.tables, k)
+	return t.merge(), t.firstPacket
+}
+
+// isComplete checks whether every section of the table has been received
+func (t *psiTable) isComplete() bool {
+	for n := 0; n <= int(t.lastSectionNumber); n++ {
+		s, ok := t.sections[uint8(n)]
+		if !ok {
+			return false
+		}
+
+		// EIT tables may be segmented in segments of 8 sections, in which case sections following the
+		// segment last section number are not transmitted
+		if s.Syntax.Data.EIT != nil && int(s.Syntax.Data.EIT.SegmentLastSectionNumber) == n {
+			n += 7 - n%8
+		}
+	}
+	return true
+}
+
+// merge merges the sections of the table into one section
+func (t *psiTable) merge() (s *PSISection) {
+	// Sort sections
+	var ns []int
+	for n := range t.sections {
+		ns = append(ns, int(n))
+	}
+	sort.Ints(ns)
+	var ss []*PSISection
+	for _, n := range ns {
+		ss = append(ss, t.sections[uint8(n)])
+	}
+
+	// Only one section
+	if len(ss) == 1 {
+		return ss[0]
+	}
+
+	// Create section
+	// Its CRC32 is the CRC32 of the sections CRC32s so that it changes whenever one of the sections changes
+	h := *ss[0].Syntax.Header
+	h.SectionNumber = 0
+	var crcs []byte
+	for _, v := range ss {
+		crcs = append(crcs, uint8(v.CRC32>>24), uint8(v.CRC32>>16), uint8(v.CRC32>>8), uint8(v.CRC32))
+	}
+	s = &PSISection{
+		CRC32:  computeCRC32(crcs),
+		Header: ss[0].Header,
+		Syntax: &PSISectionSyntax{
+			Data:   &PSISectionSyntaxData{},
+			Header: &h,
+		},
+	}
+	for _, v := range ss {
+		s.Unverified = s.Unverified || v.Unverified
+	}
+
+	// Merge data
+	f := ss[0].Syntax.Data
+	switch {
+	case f.BAT != nil:
+		d := *f.BAT
+		d.BouquetDescriptors, d.TransportStreams = nil, nil
+		for _, v := range ss {
+			d.BouquetDescriptors = append(d.BouquetDescriptors, v.Syntax.Data.BAT.BouquetDescriptors...)
+			d.TransportStreams = append(d.TransportStreams, v.Syntax.Data.BAT.TransportStreams...)
+		}
+		s.Syntax.Data.BAT = &d
+	case f.CAT != nil:
+		d := *f.CAT
+		d.Descriptors = nil
+		for _, v := range ss {
+			d.Descriptors = append(d.Descriptors, v.Syntax.Data.CAT.Descriptors...)
+		}
+		s.Syntax.Data.CAT = &d
+	case f.EIT != nil:
+		d := *f.EIT
+		d.Events = nil
+		for _, v := range ss {
+			d.Events = append(d.Events, v.Syntax.Data.EIT.Events...)
+		}
+		s.Syntax.Data.EIT = &d
+	case f.NIT != nil:
+		d := *f.NIT
+		d.NetworkDescriptors, d.TransportStreams = nil, nil
+		for _, v := range ss {
+			d.NetworkDescriptors = append(d.NetworkDescriptors, v.Syntax.Data.NIT.NetworkDescriptors...)
+			d.TransportStreams = append(d.TransportStreams, v.Syntax.Data.NIT.TransportStreams...)
+		}
+		s.Syntax.Data.NIT = &d
+	case f.PAT != nil:
+		d := *f.PAT
+		d.Programs = nil
+		for _, v := range ss {
+			d.Programs = append(d.Programs, v.Syntax.Data.PAT.Programs...)
+		}
+		s.Syntax.Data.PAT = &d
+	case f.PMT != nil:
+		d := *f.PMT
+		d.ElementaryStreams, d.ProgramDescriptors = nil, nil
+		for _, v := range ss {
+			d.ElementaryStreams = append(d.ElementaryStreams, v.Syntax.Data.PMT.ElementaryStreams...)
+			d.ProgramDescriptors = append(d.ProgramDescriptors, v.Syntax.Data.PMT.ProgramDescriptors...)
+		}
+		s.Syntax.Data.PMT = &d
+	case f.SDT != nil:
+		d := *f.SDT
+		d.Services = nil
+		for _, v := range ss {
+			d.Services = append(d.Services, v.Syntax.Data.SDT.Services...)
+		}
+		s.Syntax.Data.SDT = &d
+	default:
+		s.Syntax.Data = f
+	}
+	return
+}
@@ -0,0 +1,126 @@
+package astits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sdtSection(versionNumber, sectionNumber, lastSectionNumber uint8, serviceID uint16) *PSISection {
+	return &PSISection{
+		Header: &PSISectionHeader{TableID: PSITableIDSDTVariant1},
+		Syntax: &PSISectionSyntax{
+			Data: &PSISectionSyntaxData{SDT: &SDTData{
+				OriginalNetworkID: 2,
+				Services:          []*SDTDataService{{ServiceID: serviceID}},
+				TransportStreamID: 1,
+			}},
+			Header: &PSISectionSyntaxHeader{
+				CurrentNextIndicator: true,
+				LastSectionNumber:    lastSectionNumber,
+				SectionNumber:        sectionNumber,
+				TableIDExtension:     1,
+				VersionNumber:        versionNumber,
+			},
+		},
+	}
+}
+
+func eitSection(sectionNumber, segmentLastSectionNumber, lastSectionNumber uint8) *PSISection {
+	return &PSISection{
+		Header: &PSISectionHeader{TableID: 0x50},
+		Syntax: &PSISectionSyntax{
+			Data: &PSISectionSyntaxData{EIT: &EITData{
+				Events:                   []*EITDataEvent{{EventID: uint16(sectionNumber)}},
+				SegmentLastSectionNumber: segmentLastSectionNumber,
+				ServiceID:                1,
+			}},
+			Header: &PSISectionSyntaxHeader{
+				CurrentNextIndicator: true,
+				LastSectionNumber:    lastSectionNumber,
+				SectionNumber:        sectionNumber,
+				TableIDExtension:     1,
+			},
+		},
+	}
+}
+
+func TestPSITableAssembler(t *testing.T) {
+	c := newStreamClocks()
+	c.update(0, 0)
+	a := newPSITableAssembler(time.Second, c)
+	fp1 := &Packet{Header: PacketHeader{ContinuityCounter: 1}}
+	fp2 := &Packet{Header: PacketHeader{ContinuityCounter: 2}}
+
+	// Single section
+	s, fp := a.add(sdtSection(0, 0, 0, 1), fp1, 0x11)
+	assert.Equal(t, sdtSection(0, 0, 0, 1), s)
+	assert.Equal(t, fp1, fp)
+
+	// Several sections out of order
+	s, _ = a.add(sdtSection(0, 1, 1, 2), fp1, 0x11)
+	assert.Nil(t, s)
+	s, fp = a.add(sdtSection(0, 0, 1, 1), fp2, 0x11)
+	assert.NotNil(t, s)
+	assert.Equal(t, fp1, fp)
+	assert.Equal(t, []*SDTDataService{{ServiceID: 1}, {ServiceID: 2}}, s.Syntax.Data.SDT.Services)
+	assert.Empty(t, a.tables)
+
+	// New version discards previous sections
+	s, _ = a.add(sdtSection(0, 1, 1, 2), fp1, 0x11)
+	assert.Nil(t, s)
+	s, _ = a.add(sdtSection(1, 0, 1, 1), fp1, 0x11)
+	assert.Nil(t, s)
+	s, _ = a.add(sdtSection(1, 1, 1, 3), fp1, 0x11)
+	assert.NotNil(t, s)
+	assert.Equal(t, []*SDTDataService{{ServiceID: 1}, {ServiceID: 3}}, s.Syntax.Data.SDT.Services)
+
+	// Not applicable yet
+	n := sdtSection(2, 0, 0, 1)
+	n.Syntax.Header.CurrentNextIndicator = false
+	s, _ = a.add(n, fp1, 0x11)
+	assert.Nil(t, s)
+
+	// Timeout
+	s, _ = a.add(sdtSection(0, 1, 1, 2), fp1, 0x11)
+	assert.Nil(t, s)
+	c.update(0, 2*27000000)
+	s, _ = a.add(sdtSection(0, 0, 1, 1), fp1, 0x11)
+	assert.Nil(t, s)
+	assert.Len(t, a.tables, 1)
+
+	// Segmented EIT
+	s, _ = a.add(eitSection(0, 0, 9), fp1, 0x12)
+	assert.Nil(t, s)
+	s, _ = a.add(eitSection(9, 9, 9), fp1, 0x12)
+	assert.Nil(t, s)
+	s, _ = a.add(eitSection(8, 9, 9), fp1, 0x12)
+	assert.NotNil(t, s)
+	assert.Equal(t, []*EITDataEvent{{EventID: 0}, {EventID: 8}, {EventID: 9}}, s.Syntax.Data.EIT.Events)
+
+	// SDT of other transport streams sharing a transport stream ID but not an original network ID
+	a = newPSITableAssembler(time.Second, c)
+	o := sdtSection(0, 0, 1, 1)
+	o.Syntax.Data.SDT.OriginalNetworkID = 3
+	s, _ = a.add(sdtSection(0, 0, 1, 1), fp1, 0x11)
+	assert.Nil(t, s)
+	s, _ = a.add(o, fp1, 0x11)
+	assert.Nil(t, s)
+	assert.Len(t, a.tables, 2)
+
+	// Timeout when there's no PCR
+	c = newStreamClocks()
+	a = newPSITableAssembler(time.Second, c)
+	s, _ = a.add(sdtSection(0, 1, 1, 2), fp1, 0x11)
+	assert.Nil(t, s)
+	c.packets += a.timeoutPackets
+	s, _ = a.add(sdtSection(0, 1, 1, 3), fp1, 0x12)
+	assert.Nil(t, s)
+	assert.Len(t, a.tables, 2)
+	c.addPacket(&Packet{})
+	s, _ = a.add(sdtSection(0, 0, 1, 1), fp1, 0x11) // Would complete the first table if it hadn't timed out
+	assert.Nil(t, s)
+	assert.Len(t, a.tables, 2)
+	assert.Equal(t, int64(66489), a.timeoutPackets)
+}
@@ -0,0 +1,243 @@
+package astits
+
+import (
+	"reflect"
+)
+
+// PSITableChange describes how a PSI table has changed since it was last returned
+// Only the field matching the table type is set, and only if the table is not new
+type PSITableChange struct {
+	CRC32                 uint32
+	EIT                   *EITDataChange
+	IsNew                 bool // Whether the table appears for the first time
+	NIT                   *NITDataChange
+	PAT                   *PATDataChange
+	PMT                   *PMTDataChange
+	PreviousCRC32         uint32
+	PreviousVersionNumber uint8
+	SDT                   *SDTDataChange
+	VersionNumber         uint8
+}
+
+// EITDataChange represents the changes of an EIT data. Events are identified by their event ID.
+type EITDataChange struct {
+	AddedEvents    []*EITDataEvent
+	ModifiedEvents []*EITDataEvent
+	RemovedEvents  []*EITDataEvent
+}
+
+// NITDataChange represents the changes of a NIT data. Transport streams are identified by their transport stream ID
+// and original network ID.
+type NITDataChange struct {
+	AddedTransportStreams     []*NITDataTransportStream
+	ModifiedTransportStreams  []*NITDataTransportStream
+	NetworkDescriptorsChanged bool
+	RemovedTransportStreams   []*NITDataTransportStream
+}
+
+// PATDataChange represents the changes of a PAT data. Programs are identified by their program number.
+type PATDataChange struct {
+	AddedPrograms    []*PATProgram
+	ModifiedPrograms []*PATProgram
+	RemovedPrograms  []*PATProgram
+}
+
+// PMTDataChange represents the changes of a PMT data. Elementary streams are identified by their elementary PID.
+type PMTDataChange struct {
+	AddedElementaryStreams    []*PMTElementaryStream
+	ModifiedElementaryStreams []*PMTElementaryStream
+	PCRPIDChanged             bool
+	ProgramDescriptorsChanged bool
+	RemovedElementaryStreams  []*PMTElementaryStream
+}
+
+// SDTDataChange represents the changes of an SDT data. Services are identified by their service ID.
+type SDTDataChange struct {
+	AddedServices    []*SDTDataService
+	ModifiedServices []*SDTDataService
+	RemovedServices  []*SDTDataService
+}
+
+// psiTableTracker keeps track of the last version of each PSI table in order to detect changes
+type psiTableTracker struct {
+	sections map[psiTableTrackerKey]*PSISection
+}
+
+// psiTableTrackerKey identifies a section of a sub table
+type psiTableTrackerKey struct {
+	psiTableKey
+	sectionNumber uint8
+}
+
+// newPSITableTracker creates a new PSI table tracker
+func newPSITableTracker() *psiTableTracker {
+	return &psiTableTracker{sections: make(map[psiTableTrackerKey]*PSISection)}
+}
+
+// update updates the last version of the section's table and returns how it has changed, or nil if
+// neither its version number nor its CRC32 has changed
+func (t *psiTableTracker) update(s *PSISection, pid uint16) (c *PSITableChange) {
+	// Get key
+	k := psiTableTrackerKey{psiTableKey: newPSITableKey(s, pid)}
+	if s.Syntax.Header != nil {
+		k.sectionNumber = s.Syntax.Header.SectionNumber
+	}
+
+	// Create change
+	c = &PSITableChange{
+		CRC32:         s.CRC32,
+		VersionNumber: psiSectionVersionNumber(s),
+	}
+
+	// Get previous section
+	p, ok := t.sections[k]
+	t.sections[k] = s
+	if !ok {
+		c.IsNew = true
+		return
+	}
+
+	// Nothing has changed
+	// Tables without CRC32, such as the TDT, are compared on their data
+	c.PreviousCRC32 = p.CRC32
+	c.PreviousVersionNumber = psiSectionVersionNumber(p)
+	if c.CRC32 == c.PreviousCRC32 && c.VersionNumber == c.PreviousVersionNumber &&
+		(s.Header.TableID.hasCRC32() || reflect.DeepEqual(p.Syntax.Data, s.Syntax.Data)) {
+		return nil
+	}
+
+	// Diff data
+	pd, cd := p.Syntax.Data, s.Syntax.Data
+	switch {
+	case pd.EIT != nil && cd.EIT != nil:
+		c.EIT = diffEITData(pd.EIT, cd.EIT)
+	case pd.NIT != nil && cd.NIT != nil:
+		c.NIT = diffNITData(pd.NIT, cd.NIT)
+	case pd.PAT != nil && cd.PAT != nil:
+		c.PAT = diffPATData(pd.PAT, cd.PAT)
+	case pd.PMT != nil && cd.PMT != nil:
+		c.PMT = diffPMTData(pd.PMT, cd.PMT)
+	case pd.SDT != nil && cd.SDT != nil:
+		c.SDT = diffSDTData(pd.SDT, cd.SDT)
+	}
+	return
+}
+
+// psiSectionVersionNumber returns the version number of the section or 0 if it has no syntax header
+func psiSectionVersionNumber(s *PSISection) uint8 {
+	if s.Syntax == nil || s.Syntax.Header == nil {
+		return 0
+	}
+	return s.Syntax.Header.VersionNumber
+}
+
+// diffItems compares 2 lists of items identified by a key and calls the callbacks accordingly
+func diffItems(previousLen, currentLen int, previousKey, currentKey func(idx int) uint32, equal func(previousIdx, currentIdx int) bool, added, modified, removed func(idx int)) {
+	// Index previous items
+	ps := make(map[uint32]int, previousLen)
+	for idx := 0; idx < previousLen; idx++ {
+		ps[previousKey(idx)] = idx
+	}
+
+	// Loop through current items
+	for idx := 0; idx < currentLen; idx++ {
+		k := currentKey(idx)
+		if pidx, ok := ps[k]; !ok {
+			added(idx)
+		} else {
+			if !equal(pidx, idx) {
+				modified(idx)
+			}
+			delete(ps, k)
+		}
+	}
+
+	// Remaining previous items have been removed
+	for idx := 0; idx < previousLen; idx++ {
+		if _, ok := ps[previousKey(idx)]; ok {
+			removed(idx)
+		}
+	}
+}
+
+func diffEITData(p, c *EITData) (d *EITDataChange) {
+	d = &EITDataChange{}
+	diffItems(len(p.Events), len(c.Events),
+		func(idx int) uint32 { return uint32(p.Events[idx].EventID) },
+		func(idx int) uint32 { return uint32(c.Events[idx].EventID) },
+		func(pidx, cidx int) bool { return reflect.DeepEqual(p.Events[pidx], c.Events[cidx]) },
+		func(idx int) { d.AddedEvents = append(d.AddedEvents, c.Events[idx]) },
+		func(idx int) { d.ModifiedEvents = append(d.ModifiedEvents, c.Events[idx]) },
+		func(idx int) { d.RemovedEvents = append(d.RemovedEvents, p.Events[idx]) },
+	)
+	return
+}
+
+func diffNITData(p, c *NITData) (d *NITDataChange) {
+	d = &NITDataChange{NetworkDescriptorsChanged: !reflect.DeepEqual(p.NetworkDescriptors, c.NetworkDescriptors)}
+	diffItems(len(p.TransportStreams), len(c.TransportStreams),
+		func(idx int) uint32 {
+			return uint32(p.TransportStreams[idx].OriginalNetworkID)<<16 | uint32(p.TransportStreams[idx].TransportStreamID)
+		},
+		func(idx int) uint32 {
+			return uint32(c.TransportStreams[idx].OriginalNetworkID)<<16 | uint32(c.TransportStreams[idx].TransportStreamID)
+		},
+		func(pidx, cidx int) bool {
+			return reflect.DeepEqual(p.TransportStreams[pidx], c.TransportStreams[cidx])
+		},
+		func(idx int) { d.AddedTransportStreams = append(d.AddedTransportStreams, c.TransportStreams[idx]) },
+		func(idx int) {
+			d.ModifiedTransportStreams = append(d.ModifiedTransportStreams, c.TransportStreams[idx])
+		},
+		func(idx int) { d.RemovedTransportStreams = append(d.RemovedTransportStreams, p.TransportStreams[idx]) },
+	)
+	return
+}
+
+func diffPATData(p, c *PATData) (d *PATDataChange) {
+	d = &PATDataChange{}
+	diffItems(len(p.Programs), len(c.Programs),
+		func(idx int) uint32 { return uint32(p.Programs[idx].ProgramNumber) },
+		func(idx int) uint32 { return uint32(c.Programs[idx].ProgramNumber) },
+		func(pidx, cidx int) bool { return *p.Programs[pidx] == *c.Programs[cidx] },
+		func(idx int) { d.AddedPrograms = append(d.AddedPrograms, c.Programs[idx]) },
+		func(idx int) { d.ModifiedPrograms = append(d.ModifiedPrograms, c.Programs[idx]) },
+		func(idx int) { d.RemovedPrograms = append(d.RemovedPrograms, p.Programs[idx]) },
+	)
+	return
+}
+
+func diffPMTData(p, c *PMTData) (d *PMTDataChange) {
+	d = &PMTDataChange{
+		PCRPIDChanged:             p.PCRPID != c.PCRPID,
+		ProgramDescriptorsChanged: !reflect.DeepEqual(p.ProgramDescriptors, c.ProgramDescriptors),
+	}
+	diffItems(len(p.ElementaryStreams), len(c.ElementaryStreams),
+		func(idx int) uint32 { return uint32(p.ElementaryStreams[idx].ElementaryPID) },
+		func(idx int) uint32 { return uint32(c.ElementaryStreams[idx].ElementaryPID) },
+		func(pidx, cidx int) bool {
+			return reflect.DeepEqual(p.ElementaryStreams[pidx], c.ElementaryStreams[cidx])
+		},
+		func(idx int) { d.AddedElementaryStreams = append(d.AddedElementaryStreams, c.ElementaryStreams[idx]) },
+		func(idx int) {
+			d.ModifiedElementaryStreams = append(d.ModifiedElementaryStreams, c.ElementaryStreams[idx])
+		},
+		func(idx int) {
+			d.RemovedElementaryStreams = append(d.RemovedElementaryStreams, p.ElementaryStreams[idx])
+		},
+	)
+	return
+}
+
+func diffSDTData(p, c *SDTData) (d *SDTDataChange) {
+	d = &SDTDataChange{}
+	diffItems(len(p.Services), len(c.Services),
+		func(idx int) uint32 { return uint32(p.Services[idx].ServiceID) },
+		func(idx int) uint32 { return uint32(c.Services[idx].ServiceID) },
+		func(pidx, cidx int) bool { return reflect.DeepEqual(p.Services[pidx], c.Services[cidx]) },
+		func(idx int) { d.AddedServices = append(d.AddedServices, c.Services[idx]) },
+		func(idx int) { d.ModifiedServices = append(d.ModifiedServices, c.Services[idx]) },
+		func(idx int) { d.RemovedServices = append(d.RemovedServices, p.Services[idx]) },
+	)
+	return
+}
@@ -0,0 +1,81 @@
+package astits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pmtSection(versionNumber uint8, crc32 uint32, pcrPID uint16, ess ...*PMTElementaryStream) *PSISection {
+	return &PSISection{
+		CRC32:  crc32,
+		Header: &PSISectionHeader{TableID: PSITableIDPMT},
+		Syntax: &PSISectionSyntax{
+			Data: &PSISectionSyntaxData{PMT: &PMTData{
+				ElementaryStreams: ess,
+				PCRPID:            pcrPID,
+				ProgramNumber:     1,
+			}},
+			Header: &PSISectionSyntaxHeader{
+				CurrentNextIndicator: true,
+				TableIDExtension:     1,
+				VersionNumber:        versionNumber,
+			},
+		},
+	}
+}
+
+func TestPSITableTracker(t *testing.T) {
+	tr := newPSITableTracker()
+	es1 := &PMTElementaryStream{ElementaryPID: 0x100, StreamType: StreamTypeH264Video}
+	es2 := &PMTElementaryStream{ElementaryPID: 0x101, StreamType: StreamTypeAACAudio}
+	es2b := &PMTElementaryStream{ElementaryPID: 0x101, StreamType: StreamTypeAC3Audio}
+	es3 := &PMTElementaryStream{ElementaryPID: 0x102, StreamType: StreamTypeAACAudio}
+
+	// New
+	c := tr.update(pmtSection(0, 1, 0x100, es1, es2), 0x1000)
+	assert.Equal(t, &PSITableChange{CRC32: 1, IsNew: true}, c)
+
+	// Same table on another PID
+	c = tr.update(pmtSection(0, 1, 0x100, es1, es2), 0x1001)
+	assert.NotNil(t, c)
+	assert.True(t, c.IsNew)
+
+	// Unchanged
+	c = tr.update(pmtSection(0, 1, 0x100, es1, es2), 0x1000)
+	assert.Nil(t, c)
+
+	// Changed
+	c = tr.update(pmtSection(1, 2, 0x101, es2b, es3), 0x1000)
+	assert.Equal(t, &PSITableChange{
+		CRC32: 2,
+		PMT: &PMTDataChange{
+			AddedElementaryStreams:    []*PMTElementaryStream{es3},
+			ModifiedElementaryStreams: []*PMTElementaryStream{es2b},
+			PCRPIDChanged:             true,
+			RemovedElementaryStreams:  []*PMTElementaryStream{es1},
+		},
+		PreviousCRC32:         1,
+		PreviousVersionNumber: 0,
+		VersionNumber:         1,
+	}, c)
+
+	// Only CRC32 has changed
+	c = tr.update(pmtSection(1, 3, 0x101, es2b, es3), 0x1000)
+	assert.NotNil(t, c)
+	assert.Equal(t, &PMTDataChange{}, c.PMT)
+}
+
+func TestPSITableTrackerWithoutCRC32(t *testing.T) {
+	tr := newPSITableTracker()
+	tdtSection := func(utc time.Time) *PSISection {
+		return &PSISection{
+			Header: &PSISectionHeader{TableID: PSITableIDTDT},
+			Syntax: &PSISectionSyntax{Data: &PSISectionSyntaxData{TDT: &TDTData{UTCTime: utc}}},
+		}
+	}
+	assert.NotNil(t, tr.update(tdtSection(dvbTime), PIDTDT))
+	assert.Nil(t, tr.update(tdtSection(dvbTime), PIDTDT))
+	assert.NotNil(t, tr.update(tdtSection(dvbTime.Add(time.Second)), PIDTDT))
+}
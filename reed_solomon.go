@@ -0,0 +1,184 @@
+package astits
+
+// Reed-Solomon RS(204,188, T=8) shortened from RS(255,239, T=8), as used by DVB
+// https://www.etsi.org/deliver/etsi_en/300400_300499/300421/01.01.02_60/en_300421v010102p.pdf (4.4.2)
+// Field generator polynomial is x^8+x^4+x^3+x^2+1 and code generator polynomial is (x+α^0)(x+α^1)...(x+α^15)
+// with α = 0x02
+const (
+	reedSolomonPacketSize  = MpegTsPacketSize + reedSolomonParitySize
+	reedSolomonParitySize  = 16
+	reedSolomonPolynomial  = 0x11d
+	reedSolomonMaxErrors   = reedSolomonParitySize / 2
+	reedSolomonFieldLength = 255
+)
+
+var (
+	reedSolomonExp       [2 * reedSolomonFieldLength]byte
+	reedSolomonLog       [reedSolomonFieldLength + 1]int
+	reedSolomonGenerator []byte // Highest degree first
+)
+
+func init() {
+	// Build field tables
+	x := 1
+	for i := 0; i < reedSolomonFieldLength; i++ {
+		reedSolomonExp[i] = byte(x)
+		reedSolomonLog[x] = i
+		x <<= 1
+		if x&0x100 > 0 {
+			x ^= reedSolomonPolynomial
+		}
+	}
+	for i := reedSolomonFieldLength; i < len(reedSolomonExp); i++ {
+		reedSolomonExp[i] = reedSolomonExp[i-reedSolomonFieldLength]
+	}
+
+	// Build generator polynomial
+	reedSolomonGenerator = []byte{1}
+	for i := 0; i < reedSolomonParitySize; i++ {
+		g := make([]byte, len(reedSolomonGenerator)+1)
+		for j := range g {
+			if j < len(reedSolomonGenerator) {
+				g[j] = reedSolomonGenerator[j]
+			}
+			if j > 0 {
+				g[j] ^= gfMul(reedSolomonGenerator[j-1], reedSolomonExp[i])
+			}
+		}
+		reedSolomonGenerator = g
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return reedSolomonExp[reedSolomonLog[a]+reedSolomonLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return reedSolomonExp[reedSolomonLog[a]+reedSolomonFieldLength-reedSolomonLog[b]]
+}
+
+// gfPolyEval evaluates a polynomial whose lowest degree coefficient comes first
+func gfPolyEval(p []byte, x byte) (y byte) {
+	for i := len(p) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ p[i]
+	}
+	return
+}
+
+// computeReedSolomonParity computes the parity bytes of a 188-byte packet
+func computeReedSolomonParity(b []byte) (parity []byte) {
+	parity = make([]byte, reedSolomonParitySize)
+	for _, v := range b {
+		f := v ^ parity[0]
+		copy(parity, parity[1:])
+		parity[reedSolomonParitySize-1] = 0
+		if f == 0 {
+			continue
+		}
+		for i := range parity {
+			parity[i] ^= gfMul(f, reedSolomonGenerator[i+1])
+		}
+	}
+	return
+}
+
+// correctReedSolomon corrects in place a 204-byte packet made of a 188-byte packet followed by its parity bytes
+// It returns the number of bytes that have been corrected and whether the packet could be corrected
+func correctReedSolomon(b []byte) (corrected int, ok bool) {
+	// Compute syndromes
+	s := make([]byte, reedSolomonParitySize)
+	var hasErrors bool
+	for j := range s {
+		x := reedSolomonExp[j]
+		for _, v := range b {
+			s[j] = gfMul(s[j], x) ^ v
+		}
+		if s[j] != 0 {
+			hasErrors = true
+		}
+	}
+
+	// No errors
+	if !hasErrors {
+		return 0, true
+	}
+
+	// Compute error locator polynomial using Berlekamp-Massey
+	c, p := []byte{1}, []byte{1}
+	l, m, pd := 0, 1, byte(1)
+	for n := range s {
+		d := s[n]
+		for i := 1; i <= l && i < len(c); i++ {
+			d ^= gfMul(c[i], s[n-i])
+		}
+		if d == 0 {
+			m++
+			continue
+		}
+		t := append([]byte{}, c...)
+		f := gfDiv(d, pd)
+		if len(p)+m > len(c) {
+			c = append(c, make([]byte, len(p)+m-len(c))...)
+		}
+		for i, v := range p {
+			c[i+m] ^= gfMul(f, v)
+		}
+		if 2*l <= n {
+			l, p, pd, m = n+1-l, t, d, 1
+		} else {
+			m++
+		}
+	}
+	if l > reedSolomonMaxErrors {
+		return 0, false
+	}
+
+	// Compute error evaluator polynomial
+	e := make([]byte, reedSolomonParitySize)
+	for i := range e {
+		for j := 0; j <= i && j < len(c); j++ {
+			e[i] ^= gfMul(c[j], s[i-j])
+		}
+	}
+
+	// Compute error locator polynomial derivative
+	dc := make([]byte, len(c))
+	for i := 1; i < len(c); i += 2 {
+		dc[i-1] = c[i]
+	}
+
+	// Find error locations using Chien search and error values using Forney
+	type fix struct {
+		idx int
+		v   byte
+	}
+	var fs []fix
+	for idx := range b {
+		// Byte idx is the coefficient of degree len(b)-1-idx
+		pos := len(b) - 1 - idx
+		xi := reedSolomonExp[(reedSolomonFieldLength-pos)%reedSolomonFieldLength]
+		if gfPolyEval(c, xi) != 0 {
+			continue
+		}
+		d := gfPolyEval(dc, xi)
+		if d == 0 {
+			return 0, false
+		}
+		fs = append(fs, fix{idx: idx, v: gfMul(reedSolomonExp[pos], gfDiv(gfPolyEval(e, xi), d))})
+	}
+	if len(fs) != l {
+		return 0, false
+	}
+
+	// Correct errors
+	for _, f := range fs {
+		b[f.idx] ^= f.v
+	}
+	return len(fs), true
+}
@@ -0,0 +1,41 @@
+package astits
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// Create packet
+	p := make([]byte, MpegTsPacketSize)
+	for i := range p {
+		p[i] = byte(i * 7)
+	}
+	b := append(append([]byte{}, p...), computeReedSolomonParity(p)...)
+	assert.Len(t, b, reedSolomonPacketSize)
+
+	// No errors
+	n, ok := correctReedSolomon(b)
+	assert.True(t, ok)
+	assert.Equal(t, 0, n)
+
+	// Correctable errors
+	e := append([]byte{}, b...)
+	for _, idx := range []int{0, 3, 50, 100, 150, 187, 190, 203} {
+		e[idx] ^= 0x5a
+	}
+	n, ok = correctReedSolomon(e)
+	assert.True(t, ok)
+	assert.Equal(t, 8, n)
+	assert.True(t, bytes.Equal(b, e))
+
+	// Uncorrectable errors
+	e = append([]byte{}, b...)
+	for idx := 0; idx < 20; idx++ {
+		e[idx*10] ^= byte(idx + 1)
+	}
+	_, ok = correctReedSolomon(e)
+	assert.False(t, ok)
+}
@@ -0,0 +1,84 @@
+package astits
+
+const (
+	// clockWrap is the period of 27MHz clocks derived from 33-bit 90kHz bases, such as the PCR, the PTS and the DTS
+	clockWrap = (1 << 33) * 300
+	// Steps backwards below this duration, in 27MHz units, are considered as jitter instead of discontinuities
+	streamClockMaxJitter = 27000000
+)
+
+// streamClock turns a clock that wraps and may go backwards into a monotonic stream time, in 27MHz units
+// Only positive deltas between consecutive clock values are added, taking the 33-bit wrap into account. Going
+// backwards doesn't move the stream time: small steps, as with timestamps of interleaved elementary streams, are
+// ignored, and following deltas are computed from the new value after larger ones, as on a discontinuity.
+type streamClock struct {
+	hasLast bool
+	last    int64 // Last clock value, in 27MHz units
+	now     int64 // Stream time, in 27MHz units
+}
+
+// update updates the clock with a new clock value, in 27MHz units, and returns the stream time
+func (c *streamClock) update(v int64) int64 {
+	if c.hasLast {
+		// Deltas above half the wrap period are steps backwards
+		if d := ((v-c.last)%clockWrap + clockWrap) % clockWrap; d < clockWrap/2 {
+			c.now += d
+		} else if clockWrap-d < streamClockMaxJitter {
+			return c.now
+		}
+	}
+	c.hasLast = true
+	c.last = v
+	return c.now
+}
+
+// streamClocks keeps track of the stream time based on several clocks, such as the PCRs of every PID carrying one or
+// the timestamps of every program, which may be unrelated in multi program transport streams
+type streamClocks struct {
+	// We use map[uint32] instead map[uint16] as go runtime provide optimized hash functions for (u)int32/64 keys
+	clocks  map[uint32]*streamClock
+	now     int64 // Stream time in 27MHz units, which is the time of the clock that has advanced the most
+	packets int64 // Number of packets added, with or without PCR
+}
+
+// newStreamClocks creates new stream clocks
+func newStreamClocks() *streamClocks {
+	return &streamClocks{clocks: make(map[uint32]*streamClock)}
+}
+
+// addPacket updates the stream time with the PCR of the packet, if any, using a clock per PID
+func (c *streamClocks) addPacket(p *Packet) {
+	c.packets++
+
+	// No PCR
+	if !p.Header.HasAdaptationField || p.AdaptationField == nil || !p.AdaptationField.HasPCR || p.AdaptationField.PCR == nil {
+		return
+	}
+	c.update(uint32(p.Header.PID), p.AdaptationField.PCR.Base*300+p.AdaptationField.PCR.Extension)
+}
+
+// update updates the clock k with a new clock value, in 27MHz units, and returns the stream time
+func (c *streamClocks) update(k uint32, v int64) int64 {
+	// Get clock
+	sc, ok := c.clocks[k]
+	if !ok {
+		sc = &streamClock{}
+		c.clocks[k] = sc
+	}
+
+	// Update stream time
+	if t := sc.update(v); t > c.now {
+		c.now = t
+	}
+	return c.now
+}
+
+// hasTime checks whether the stream time is available, which is not the case until a clock value has been received
+func (c *streamClocks) hasTime() bool {
+	return len(c.clocks) > 0
+}
+
+// time returns the stream time in 27MHz units
+func (c *streamClocks) time() int64 {
+	return c.now
+}
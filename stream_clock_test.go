@@ -0,0 +1,39 @@
+package astits
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamClock(t *testing.T) {
+	c := &streamClock{}
+	assert.Equal(t, int64(0), c.update(clockWrap-300))
+	assert.Equal(t, int64(600), c.update(300))                        // Wrap
+	assert.Equal(t, int64(600), c.update(100))                        // Jitter
+	assert.Equal(t, int64(700), c.update(400))                        // Forward from the last value
+	assert.Equal(t, int64(700), c.update(400-2*streamClockMaxJitter)) // Discontinuity
+	assert.Equal(t, int64(800), c.update(500-2*streamClockMaxJitter)) // Forward from the new value
+	assert.Equal(t, int64(800), c.update(clockWrap/2))                // Too far ahead to be a forward step
+}
+
+func TestStreamClocks(t *testing.T) {
+	c := newStreamClocks()
+	pcr := func(pid uint16, base int64) *Packet {
+		return &Packet{
+			AdaptationField: &PacketAdaptationField{HasPCR: true, PCR: newClockReference(base, 0)},
+			Header:          PacketHeader{HasAdaptationField: true, PID: pid},
+		}
+	}
+	c.addPacket(pcr(0x100, 1000))
+	c.addPacket(pcr(0x200, 50000))
+	c.addPacket(&Packet{Header: PacketHeader{PID: 0x100}})
+	c.addPacket(pcr(0x100, 1100))
+	assert.Equal(t, int64(30000), c.time())
+	c.addPacket(pcr(0x200, 50050))
+	assert.Equal(t, int64(30000), c.time())
+	c.addPacket(pcr(0x200, 50200))
+	assert.Equal(t, int64(60000), c.time())
+	assert.Equal(t, int64(60000), c.update(1, 0))
+	assert.Equal(t, int64(90000), c.update(1, 90000))
+}